@@ -2,51 +2,128 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"time"
 
 	"vinted/otel-workshop/internal/buyer"
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/chaos"
 	"vinted/otel-workshop/internal/config"
+	"vinted/otel-workshop/internal/health"
+	"vinted/otel-workshop/internal/order"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/internal/shutdown"
+	"vinted/otel-workshop/internal/telemetry"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 )
 
 type BuyerConfig struct {
-	BuyerAddress   string        `envconfig:"BUYER_SERVICE_ADDR" validate:"required"`
-	BuyingInterval time.Duration `envconfig:"BUYER_SERVICE_BUY_INTERVAL" validate:"required"`
-	ShopAddress    string        `envconfig:"SHOP_SERVICE_ADDR" validate:"required"`
-	FactoryAddress string        `envconfig:"FACTORY_SERVICE_ADDR" validate:"required"`
+	BuyerAddress       string        `envconfig:"BUYER_SERVICE_ADDR" validate:"required"`
+	BuyingInterval     time.Duration `envconfig:"BUYER_SERVICE_BUY_INTERVAL" validate:"required"`
+	BuyingStrategy     string        `envconfig:"BUYER_SERVICE_STRATEGY" default:"random" validate:"oneof=random popular greedy bursty diurnal cart reserve"`
+	ShopAddress        string        `envconfig:"SHOP_SERVICE_ADDR" validate:"required"`
+	FactoryAddress     string        `envconfig:"FACTORY_SERVICE_ADDR" validate:"required"`
+	RedisAddress       string        `envconfig:"REDIS_SERVICE_ADDR" validate:"required"`
+	CatalogBackend     string        `envconfig:"CATALOG_BACKEND" default:"file" validate:"oneof=file redis"`
+	CatalogFile        string        `envconfig:"CATALOG_FILE"`
+	RandomSeed         uint64        `envconfig:"RANDOM_SEED"`
+	ChaosConfig        string        `envconfig:"CHAOS_CONFIG"`
+	HealthCheckTimeout time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+	ShutdownTimeout    time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s"`
 }
 
 func main() {
 	logger := logrus.New()
 	logger.SetOutput(os.Stdout)
 	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(telemetry.LogrusHook("buyer"))
 
 	cfg, err := config.Load[BuyerConfig]()
 	if err != nil {
 		logger.Fatalf("new config: %v", err)
 	}
+
+	flushTelemetry, err := telemetry.Setup(context.Background(), "buyer")
+	if err != nil {
+		logger.Fatalf("setup telemetry: %v", err)
+	}
+
 	logger.WithFields(logrus.Fields{
 		"buyer_address":   cfg.BuyerAddress,
 		"buying_interval": cfg.BuyingInterval,
+		"buying_strategy": cfg.BuyingStrategy,
 		"shop_address":    cfg.ShopAddress,
 		"factory_address": cfg.FactoryAddress,
+		"redis_address":   cfg.RedisAddress,
 	}).Info("starting buyer service")
 
-	g, ctx := errgroup.WithContext(context.Background())
+	// Orders are advanced by the factory and the warehouse, so they have to
+	// live where those services can reach them.
+	orders := order.NewRedisStore(cfg.RedisAddress)
 
-	g.Go(func() error {
-		server := buyer.NewBuyerServer(logger, cfg.FactoryAddress, http.Client{})
+	cat, err := catalog.Open(context.Background(), cfg.CatalogBackend, cfg.CatalogFile, cfg.RedisAddress)
+	if err != nil {
+		logger.Fatalf("open catalog: %v", err)
+	}
+
+	server := buyer.NewBuyerServer(logger, cfg.FactoryAddress, http.Client{
+		Transport: telemetry.HTTPTransport(http.DefaultTransport),
+	}, orders, cat)
+
+	shopBuyer, err := buyer.NewShopBuyer(logger, cfg.ShopAddress, cat, random.New(cfg.RandomSeed), telemetry.GRPCDialOptions()...)
+	if err != nil {
+		logger.Fatalf("failed to create buyer: %v", err)
+	}
+
+	strategyBuyer, err := buyer.NewStrategy(cfg.BuyingStrategy, shopBuyer)
+	if err != nil {
+		logger.Fatalf("failed to create buyer: %v", err)
+	}
+
+	injector := chaos.New(random.New(cfg.RandomSeed))
+	if cfg.ChaosConfig != "" {
+		if err := injector.Load(cfg.ChaosConfig); err != nil {
+			logger.Fatalf("load chaos config: %v", err)
+		}
+	}
+
+	checker := health.NewChecker(cfg.HealthCheckTimeout)
+	checker.Add("shop", shopBuyer.Ping)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /order", server.HandleOrder)
+	mux.HandleFunc("GET /orders", server.HandleListOrders)
+	mux.HandleFunc("GET /orders/{id}", server.HandleGetOrder)
+	mux.HandleFunc("GET /customers", shopBuyer.HandleListCustomers)
+	mux.HandleFunc("GET /customers/{id}/purchases", shopBuyer.HandlePurchaseHistory)
+	checker.Register(mux)
+	injector.Register(mux)
+
+	httpServer := &http.Server{
+		Addr:    cfg.BuyerAddress,
+		Handler: telemetry.HTTPHandler(mux, injector.Middleware),
+	}
+
+	var steps shutdown.Sequence
+	steps.Add("http server", httpServer.Shutdown)
+	steps.AddCloser("shop client", shopBuyer)
+	steps.AddCloser("order store", orders)
+	steps.AddCloser("catalog", cat)
+	steps.Add("telemetry", flushTelemetry)
 
-		mux := http.NewServeMux()
-		mux.HandleFunc("/order", server.HandleOrder)
+	ctx, stop := shutdown.NotifyContext(context.Background())
+	defer stop()
 
-		err = http.ListenAndServe(cfg.BuyerAddress, mux)
-		if err != nil {
-			logger.Fatalf("server failed: %v", err)
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		err := httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("server failed: %v", err)
 			return err
 		}
 
@@ -54,27 +131,38 @@ func main() {
 	})
 
 	g.Go(func() error {
-		buyer, err := buyer.NewRandomBuyer(logger, cfg.ShopAddress)
-		if err != nil {
-			logger.Fatalf("failed to create buyer: %v", err)
-		}
-
 		ticker := time.NewTicker(cfg.BuyingInterval)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			logger.Info("buying product")
-			if err := buyer.Buy(ctx); err != nil {
-				logger.Fatalf("failed to buy: %v", err)
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+
+			logger.WithContext(ctx).Info("buying product")
+			if err := strategyBuyer.Buy(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				logger.Errorf("failed to buy: %v", err)
 				return err
 			}
 		}
+	})
 
-		return nil
+	g.Go(func() error {
+		<-ctx.Done()
+		logger.Info("shutting down buyer service")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		return steps.Run(shutdownCtx)
 	})
 
 	if err := g.Wait(); err != nil {
 		logger.Fatalf("buyer failed: %v", err)
 	}
-
 }
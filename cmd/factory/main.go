@@ -2,12 +2,22 @@ package main
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
 	"time"
 
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/chaos"
 	"vinted/otel-workshop/internal/config"
 	"vinted/otel-workshop/internal/factory"
+	"vinted/otel-workshop/internal/health"
+	"vinted/otel-workshop/internal/kafka"
+	"vinted/otel-workshop/internal/order"
+	"vinted/otel-workshop/internal/product"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/internal/shutdown"
+	"vinted/otel-workshop/internal/telemetry"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -18,11 +28,46 @@ type FactoryConfig struct {
 	FactoryKafkaTopic       string        `envconfig:"FACTORY_SERVICE_KAFKA_TOPIC" validate:"required"`
 	FactoryMaxProduction    int           `envconfig:"FACTORY_SERVICE_MAX_PRODUCTION" validate:"required"`
 	FactoryShippingInterval time.Duration `envconfig:"FACTORY_SERVICE_SHIPPING_INTERVAL" validate:"required"`
+	FactoryKafkaEncoding    string        `envconfig:"FACTORY_SERVICE_KAFKA_ENCODING" default:"json" validate:"oneof=json protobuf"`
+	FactoryKafkaProducer    string        `envconfig:"FACTORY_SERVICE_KAFKA_PRODUCER" default:"sync" validate:"oneof=sync async"`
+	FactoryKafkaBatchSize   int           `envconfig:"FACTORY_SERVICE_KAFKA_BATCH_SIZE" default:"100"`
+	FactoryKafkaLinger      time.Duration `envconfig:"FACTORY_SERVICE_KAFKA_LINGER" default:"10ms"`
+	FactoryKafkaCompression string        `envconfig:"FACTORY_SERVICE_KAFKA_COMPRESSION" default:"none"`
+	FactoryKafkaMaxInFlight int           `envconfig:"FACTORY_SERVICE_KAFKA_MAX_IN_FLIGHT" default:"5" validate:"min=1"`
+	FactoryOrdersTopic      string        `envconfig:"FACTORY_SERVICE_ORDERS_TOPIC" default:"orders"`
+	FactoryOrdersGroup      string        `envconfig:"FACTORY_SERVICE_ORDERS_CONSUMER_GROUP" default:"factory-orders"`
+	FactoryOrderChunkSize   int           `envconfig:"FACTORY_SERVICE_ORDER_CHUNK_SIZE" default:"100" validate:"min=1"`
+	FactoryMaxOrderQuantity int64         `envconfig:"FACTORY_SERVICE_MAX_ORDER_QUANTITY" default:"1000000" validate:"min=1"`
+	RedisAddress            string        `envconfig:"REDIS_SERVICE_ADDR" validate:"required_if=CatalogBackend redis"`
+	CatalogBackend          string        `envconfig:"CATALOG_BACKEND" default:"file" validate:"oneof=file redis"`
+	CatalogFile             string        `envconfig:"CATALOG_FILE"`
+	RandomSeed              uint64        `envconfig:"RANDOM_SEED"`
+	ChaosConfig             string        `envconfig:"CHAOS_CONFIG"`
+	HealthCheckTimeout      time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+	ShutdownTimeout         time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s"`
+}
+
+type shipper interface {
+	factory.Shipper
+	io.Closer
+}
+
+func newShipper(logger *slog.Logger, cfg FactoryConfig, encoding product.Encoding) (shipper, error) {
+	if cfg.FactoryKafkaProducer == "async" {
+		return factory.NewAsyncKafkaShipper(logger, cfg.KafkaBrokers, cfg.FactoryKafkaTopic, encoding, factory.AsyncShipperConfig{
+			BatchSize:   cfg.FactoryKafkaBatchSize,
+			Linger:      cfg.FactoryKafkaLinger,
+			Compression: cfg.FactoryKafkaCompression,
+			MaxInFlight: cfg.FactoryKafkaMaxInFlight,
+		})
+	}
+
+	return factory.NewKafkaShipper(logger, cfg.KafkaBrokers, cfg.FactoryKafkaTopic, encoding)
 }
 
 func main() {
 	logger := slog.New(
-		slog.NewJSONHandler(os.Stdout, nil),
+		telemetry.SlogHandler("factory", slog.NewJSONHandler(os.Stdout, nil)),
 	)
 
 	cfg, err := config.Load[FactoryConfig]()
@@ -31,43 +76,150 @@ func main() {
 		os.Exit(1)
 	}
 
-	g, ctx := errgroup.WithContext(context.Background())
+	flushTelemetry, err := telemetry.Setup(context.Background(), "factory")
+	if err != nil {
+		logger.Error("setup telemetry", "error", err)
+		os.Exit(1)
+	}
 
-	g.Go(func() error {
-		shipper, err := factory.NewKafkaShipper(logger, cfg.KafkaBrokers, cfg.FactoryKafkaTopic)
-		if err != nil {
-			logger.Error("failed to create Kafka shipper", "error", err)
-			return err
+	encoding, err := product.ParseEncoding(cfg.FactoryKafkaEncoding)
+	if err != nil {
+		logger.Error("invalid encoding", "error", err)
+		os.Exit(1)
+	}
+
+	shipper, err := newShipper(logger, cfg, encoding)
+	if err != nil {
+		logger.Error("failed to create Kafka shipper", "error", err)
+		os.Exit(1)
+	}
+
+	orderShipper, err := newShipper(logger, cfg, encoding)
+	if err != nil {
+		logger.Error("failed to create orders Kafka shipper", "error", err)
+		os.Exit(1)
+	}
+
+	orderQueue, err := factory.NewKafkaOrderQueue(logger, cfg.KafkaBrokers, cfg.FactoryOrdersTopic, cfg.FactoryOrdersGroup, encoding)
+	if err != nil {
+		logger.Error("failed to create Kafka order queue", "error", err)
+		os.Exit(1)
+	}
+
+	var orders order.Store
+	var redisOrders *order.RedisStore
+	if cfg.RedisAddress != "" {
+		redisOrders = order.NewRedisStore(cfg.RedisAddress)
+		orders = redisOrders
+	}
+
+	cat, err := catalog.Open(context.Background(), cfg.CatalogBackend, cfg.CatalogFile, cfg.RedisAddress)
+	if err != nil {
+		logger.Error("open catalog", "error", err)
+		os.Exit(1)
+	}
+
+	kafkaChecker, err := kafka.NewMetadataChecker(cfg.KafkaBrokers, cfg.FactoryKafkaTopic, cfg.FactoryOrdersTopic)
+	if err != nil {
+		logger.Error("failed to create Kafka health check", "error", err)
+		os.Exit(1)
+	}
+
+	injector := chaos.New(random.New(cfg.RandomSeed))
+	if cfg.ChaosConfig != "" {
+		if err := injector.Load(cfg.ChaosConfig); err != nil {
+			logger.Error("load chaos config", "error", err)
+			os.Exit(1)
 		}
+	}
+
+	checker := health.NewChecker(cfg.HealthCheckTimeout)
+	checker.Add("kafka", kafkaChecker.Check)
+
+	productFactory := factory.NewProductFactory(logger, cfg.FactoryMaxProduction, cat, factory.NewChaosShipper(shipper, injector), random.New(cfg.RandomSeed))
+	orderWorker := factory.NewOrderWorker(logger, factory.NewChaosShipper(orderShipper, injector), orders, cfg.FactoryOrderChunkSize)
+	server := factory.NewFactoryServer(logger, cfg.FactoryAddress, orderQueue, cat, cfg.FactoryMaxOrderQuantity, checker, injector)
+
+	producing := make(chan struct{})
+	working := make(chan struct{})
+
+	var steps shutdown.Sequence
+	steps.Add("http server", server.Shutdown)
+	steps.Add("product factory", func(ctx context.Context) error {
+		select {
+		case <-producing:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	steps.Add("order worker", func(ctx context.Context) error {
+		select {
+		case <-working:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	steps.AddCloser("Kafka order queue", orderQueue)
+	if redisOrders != nil {
+		steps.AddCloser("order store", redisOrders)
+	}
+	steps.AddCloser("catalog", cat)
+	steps.AddCloser("orders Kafka shipper", orderShipper)
+	steps.AddCloser("Kafka shipper", shipper)
+	steps.AddCloser("Kafka health check", kafkaChecker)
+	steps.Add("telemetry", flushTelemetry)
+
+	ctx, stop := shutdown.NotifyContext(context.Background())
+	defer stop()
 
-		factory := factory.NewProductFactory(logger, cfg.FactoryMaxProduction, shipper)
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		defer close(producing)
 
 		ticker := time.NewTicker(cfg.FactoryShippingInterval)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			err = factory.Produce(ctx)
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+
+			err := productFactory.Produce(ctx)
 			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
 				logger.Error("failed to produce products", "error", err)
 				return err
 			}
 		}
-
-		return nil
 	})
 
 	g.Go(func() error {
-		orderShipper, err := factory.NewKafkaShipper(logger, cfg.KafkaBrokers, cfg.FactoryKafkaTopic)
-		if err != nil {
-			logger.Error("failed to create orders Kafka shipper", "error", err)
-			return err
-		}
+		defer close(working)
 
-		server := factory.NewFactoryServer(logger, cfg.FactoryAddress, orderShipper)
+		return orderQueue.Work(ctx, orderWorker)
+	})
 
+	g.Go(func() error {
 		return server.StartAndRun()
 	})
 
+	g.Go(func() error {
+		<-ctx.Done()
+		logger.Info("shutting down factory service")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		return steps.Run(shutdownCtx)
+	})
+
 	if err := g.Wait(); err != nil {
 		logger.Error("factory failed", "error", err)
 		os.Exit(1)
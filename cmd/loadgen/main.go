@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"vinted/otel-workshop/internal/buyer"
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/config"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/internal/shutdown"
+	"vinted/otel-workshop/internal/telemetry"
+
+	"github.com/sirupsen/logrus"
+)
+
+type LoadgenConfig struct {
+	ShopAddress    string `envconfig:"SHOP_SERVICE_ADDR" validate:"required"`
+	Scenario       string `envconfig:"LOADGEN_SCENARIO" validate:"required"`
+	RedisAddress   string `envconfig:"REDIS_SERVICE_ADDR" validate:"required_if=CatalogBackend redis"`
+	CatalogBackend string `envconfig:"CATALOG_BACKEND" default:"file" validate:"oneof=file redis"`
+	CatalogFile    string `envconfig:"CATALOG_FILE"`
+	RandomSeed     uint64 `envconfig:"RANDOM_SEED"`
+}
+
+func main() {
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(telemetry.LogrusHook("loadgen"))
+
+	cfg, err := config.Load[LoadgenConfig]()
+	if err != nil {
+		logger.Fatalf("new config: %v", err)
+	}
+
+	scenario, err := buyer.LoadScenario(cfg.Scenario)
+	if err != nil {
+		logger.Fatalf("load scenario: %v", err)
+	}
+
+	flushTelemetry, err := telemetry.Setup(context.Background(), "loadgen")
+	if err != nil {
+		logger.Fatalf("setup telemetry: %v", err)
+	}
+	defer func() {
+		if err := flushTelemetry(context.Background()); err != nil {
+			logger.Errorf("shutdown telemetry: %v", err)
+		}
+	}()
+
+	cat, err := catalog.Open(context.Background(), cfg.CatalogBackend, cfg.CatalogFile, cfg.RedisAddress)
+	if err != nil {
+		logger.Fatalf("open catalog: %v", err)
+	}
+	defer cat.Close()
+
+	shopBuyer, err := buyer.NewShopBuyer(logger, cfg.ShopAddress, cat, random.New(cfg.RandomSeed), telemetry.GRPCDialOptions()...)
+	if err != nil {
+		logger.Fatalf("failed to create buyer: %v", err)
+	}
+	defer shopBuyer.Close()
+
+	ctx, stop := shutdown.NotifyContext(context.Background())
+	defer stop()
+
+	logger.WithField("scenario", cfg.Scenario).Info("running scenario")
+
+	if err := scenario.Run(ctx, logger, shopBuyer); err != nil {
+		logger.Errorf("scenario interrupted: %v", err)
+		return
+	}
+
+	logger.Info("scenario finished")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"vinted/otel-workshop/internal/config"
+	"vinted/otel-workshop/internal/telemetry"
+	"vinted/otel-workshop/internal/warehouse"
+)
+
+type ReplayConfig struct {
+	KafkaBrokers        []string      `envconfig:"KAFKA_SERVICE_ADDR" validate:"required"`
+	DeadLetterTopic     string        `envconfig:"WAREHOUSE_SERVICE_DLQ_TOPIC" validate:"required"`
+	ReplayConsumerGroup string        `envconfig:"REPLAY_CONSUMER_GROUP" default:"warehouse-dlq-replay"`
+	ReplayIdleTimeout   time.Duration `envconfig:"REPLAY_IDLE_TIMEOUT" default:"10s"`
+}
+
+func main() {
+	logger := slog.New(
+		telemetry.SlogHandler("replay", slog.NewJSONHandler(os.Stdout, nil)),
+	)
+
+	cfg, err := config.Load[ReplayConfig]()
+	if err != nil {
+		logger.Error("new config", "error", err)
+		os.Exit(1)
+	}
+
+	if err := run(logger, cfg); err != nil {
+		logger.Error("failed to replay", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run replays the dead-lettered messages. It returns instead of exiting so
+// that the replayer and telemetry are closed before a failed run exits.
+func run(logger *slog.Logger, cfg ReplayConfig) error {
+	shutdown, err := telemetry.Setup(context.Background(), "replay")
+	if err != nil {
+		return fmt.Errorf("setup telemetry: %w", err)
+	}
+	defer func() {
+		if err := shutdown(context.Background()); err != nil {
+			logger.Error("shutdown telemetry", "error", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	replayer, err := warehouse.NewReplayer(logger, cfg.KafkaBrokers, cfg.DeadLetterTopic, cfg.ReplayConsumerGroup, cfg.ReplayIdleTimeout)
+	if err != nil {
+		return fmt.Errorf("create replayer: %w", err)
+	}
+	defer func() {
+		if err := replayer.Close(); err != nil {
+			logger.Error("failed to close replayer", "error", err)
+		}
+	}()
+
+	logger.Info("replaying dead-lettered messages", "topic", cfg.DeadLetterTopic)
+
+	count, err := replayer.Replay(ctx)
+	if err != nil {
+		return fmt.Errorf("replay after %d messages: %w", count, err)
+	}
+
+	logger.Info("replayed dead-lettered messages", "count", count)
+
+	return nil
+}
@@ -2,30 +2,70 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/chaos"
 	"vinted/otel-workshop/internal/config"
+	"vinted/otel-workshop/internal/health"
+	"vinted/otel-workshop/internal/random"
 	"vinted/otel-workshop/internal/shop"
-	"vinted/otel-workshop/pb/genproto/otelworkshop"
+	"vinted/otel-workshop/internal/shutdown"
+	"vinted/otel-workshop/internal/telemetry"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/reflection"
+	grpchealth "google.golang.org/grpc/health"
 )
 
 type ShopConfig struct {
 	RedisAddress                string        `envconfig:"REDIS_SERVICE_ADDR" validate:"required"`
+	CatalogBackend              string        `envconfig:"CATALOG_BACKEND" default:"file" validate:"oneof=file redis"`
+	CatalogFile                 string        `envconfig:"CATALOG_FILE"`
 	ShopAddress                 string        `envconfig:"SHOP_SERVICE_ADDR" validate:"required"`
+	ShopAdminAddress            string        `envconfig:"SHOP_SERVICE_ADMIN_ADDR" validate:"required"`
 	ShopInventoryUpdateInterval time.Duration `envconfig:"SHOP_SERVICE_INVENTORY_UPDATE_INTERVAL" validate:"required"`
+	ShopHealthCheckInterval     time.Duration `envconfig:"SHOP_SERVICE_HEALTH_CHECK_INTERVAL" default:"5s"`
+	ShopReservationTTL          time.Duration `envconfig:"SHOP_SERVICE_RESERVATION_TTL" default:"30s"`
+	ShopReservationReapInterval time.Duration `envconfig:"SHOP_SERVICE_RESERVATION_REAP_INTERVAL" default:"5s"`
+	ShopCustomerRateLimit       int64         `envconfig:"SHOP_SERVICE_CUSTOMER_RATE_LIMIT" default:"0" validate:"min=0"`
+	ShopCustomerRateWindow      time.Duration `envconfig:"SHOP_SERVICE_CUSTOMER_RATE_WINDOW" default:"1m" validate:"required_with=ShopCustomerRateLimit"`
+	RandomSeed                  uint64        `envconfig:"RANDOM_SEED"`
+	ChaosConfig                 string        `envconfig:"CHAOS_CONFIG"`
+	HealthCheckTimeout          time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+	ShutdownTimeout             time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s"`
+}
+
+// probe is the container health check: "shop healthcheck" asks the running
+// shop for its grpc.health.v1 status.
+func probe() {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := health.ProbeGRPC(ctx, os.Getenv("SHOP_SERVICE_ADDR")); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
 func main() {
-	logger := zap.Must(zap.NewProduction())
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		probe()
+		return
+	}
+
+	logger := zap.Must(zap.NewProduction(
+		zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return telemetry.ZapCore("shop", core)
+		}),
+	))
 	defer func() {
 		_ = logger.Sync()
 	}()
@@ -35,56 +75,165 @@ func main() {
 		logger.Fatal("new config", zap.Error(err))
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	flushTelemetry, err := telemetry.Setup(context.Background(), "shop")
+	if err != nil {
+		logger.Fatal("setup telemetry", zap.Error(err))
+	}
 
-	shop := shop.NewRedisShop(logger, cfg.RedisAddress)
-	if err = shop.UpdateInventory(ctx); err != nil {
+	ctx, stop := shutdown.NotifyContext(context.Background())
+	defer stop()
+
+	injector := chaos.New(random.New(cfg.RandomSeed))
+	if cfg.ChaosConfig != "" {
+		if err := injector.Load(cfg.ChaosConfig); err != nil {
+			logger.Fatal("load chaos config", zap.Error(err))
+		}
+	}
+
+	cat, err := catalog.Open(ctx, cfg.CatalogBackend, cfg.CatalogFile, cfg.RedisAddress)
+	if err != nil {
+		logger.Fatal("open catalog", zap.Error(err))
+	}
+
+	redisShop := shop.NewRedisShop(logger, cfg.RedisAddress, cat, shop.Config{
+		ReservationTTL:     cfg.ShopReservationTTL,
+		CustomerRateLimit:  cfg.ShopCustomerRateLimit,
+		CustomerRateWindow: cfg.ShopCustomerRateWindow,
+	}, injector.RedisHook())
+	if err = redisShop.UpdateInventory(ctx); err != nil {
 		logger.Fatal("failed to update inventory", zap.Error(err))
 	}
 
+	listen, err := net.Listen("tcp", cfg.ShopAddress)
+	if err != nil {
+		logger.Fatal("failed to listen", zap.String("address", cfg.ShopAddress), zap.Error(err))
+	}
+
+	checker := health.NewChecker(cfg.HealthCheckTimeout)
+	checker.Add("redis", redisShop.Ping)
+
+	healthServer := grpchealth.NewServer()
+	grpcServer := shop.NewGRPCServer(redisShop, healthServer, append(
+		telemetry.GRPCServerOptions(),
+		grpc.ChainUnaryInterceptor(injector.UnaryServerInterceptor()),
+	)...)
+
+	adminMux := http.NewServeMux()
+	injector.Register(adminMux)
+
+	adminServer := &http.Server{
+		Addr:    cfg.ShopAdminAddress,
+		Handler: adminMux,
+	}
+
+	var steps shutdown.Sequence
+	steps.Add("health", func(context.Context) error {
+		healthServer.Shutdown()
+		return nil
+	})
+	steps.Add("admin server", adminServer.Shutdown)
+	steps.Add("inventory watchers", func(context.Context) error {
+		redisShop.CloseWatchers()
+		return nil
+	})
+	steps.Add("grpc server", func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			grpcServer.Stop()
+			return ctx.Err()
+		}
+	})
+	steps.AddCloser("redis", redisShop)
+	steps.AddCloser("catalog", cat)
+	steps.Add("telemetry", flushTelemetry)
+
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
 		ticker := time.NewTicker(cfg.ShopInventoryUpdateInterval)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			logger.Info("updating inventory")
-			if err := shop.UpdateInventory(ctx); err != nil {
-				logger.Error("failed to update inventory", zap.Error(err))
-				return err
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+
+			// Stock changes are followed through the inventory stream; this
+			// only resyncs whatever was missed.
+			logger.Info("resyncing inventory")
+			if err := redisShop.UpdateInventory(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("failed to resync inventory", zap.Error(err))
 			}
 		}
+	})
 
+	g.Go(func() error {
+		redisShop.FollowInventory(ctx)
 		return nil
 	})
 
 	g.Go(func() error {
-		listen, err := net.Listen("tcp", cfg.ShopAddress)
-		if err != nil {
-			logger.Error("failed to listen", zap.String("address", cfg.ShopAddress), zap.Error(err))
+		ticker := time.NewTicker(cfg.ShopReservationReapInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+
+			if err := redisShop.ReleaseExpiredReservations(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("failed to release expired reservations", zap.Error(err))
+			}
+		}
+	})
+
+	g.Go(func() error {
+		checker.Serve(ctx, healthServer, cfg.ShopHealthCheckInterval)
+		return nil
+	})
+
+	g.Go(func() error {
+		err := adminServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("admin server failed", zap.Error(err))
 			return err
 		}
 
-		grpcServer := grpc.NewServer()
-		otelworkshop.RegisterShopServiceServer(grpcServer, shop)
-		reflection.Register(grpcServer)
+		return nil
+	})
 
+	g.Go(func() error {
 		logger.Info("starting server", zap.String("address", cfg.ShopAddress))
 		if err := grpcServer.Serve(listen); err != nil {
 			logger.Error("failed to serve", zap.Error(err))
 			return err
 		}
 
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-		<-sigChan
-		grpcServer.GracefulStop()
-
 		return nil
 	})
 
+	g.Go(func() error {
+		<-ctx.Done()
+		logger.Info("shutting down shop service")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		return steps.Run(shutdownCtx)
+	})
+
 	if err := g.Wait(); err != nil {
 		logger.Fatal("shop failed", zap.Error(err))
 	}
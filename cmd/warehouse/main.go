@@ -2,23 +2,46 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"net/http"
 	"os"
+	"time"
 
+	"vinted/otel-workshop/internal/chaos"
 	"vinted/otel-workshop/internal/config"
+	"vinted/otel-workshop/internal/health"
+	"vinted/otel-workshop/internal/kafka"
+	"vinted/otel-workshop/internal/order"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/internal/shutdown"
+	"vinted/otel-workshop/internal/telemetry"
 	"vinted/otel-workshop/internal/warehouse"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type WarehouseConfig struct {
-	KafkaBrokers           []string `envconfig:"KAFKA_SERVICE_ADDR" validate:"required"`
-	RedisAddress           string   `envconfig:"REDIS_SERVICE_ADDR" validate:"required"`
-	WarehouseTopic         string   `envconfig:"FACTORY_SERVICE_KAFKA_TOPIC" validate:"required"`
-	WarehouseConsumerGroup string   `envconfig:"WAREHOUSE_SERVICE_CONSUMER_GROUP" validate:"required"`
+	KafkaBrokers             []string      `envconfig:"KAFKA_SERVICE_ADDR" validate:"required"`
+	RedisAddress             string        `envconfig:"REDIS_SERVICE_ADDR" validate:"required"`
+	WarehouseAddress         string        `envconfig:"WAREHOUSE_SERVICE_ADDR" validate:"required"`
+	WarehouseTopic           string        `envconfig:"FACTORY_SERVICE_KAFKA_TOPIC" validate:"required"`
+	WarehouseConsumerGroup   string        `envconfig:"WAREHOUSE_SERVICE_CONSUMER_GROUP" validate:"required"`
+	WarehouseDeadLetterTopic string        `envconfig:"WAREHOUSE_SERVICE_DLQ_TOPIC" validate:"required"`
+	WarehouseBatchSize       int           `envconfig:"WAREHOUSE_SERVICE_BATCH_SIZE" default:"100" validate:"min=1"`
+	WarehouseRetries         int           `envconfig:"WAREHOUSE_SERVICE_RETRIES" default:"3"`
+	WarehouseRetryBackoff    time.Duration `envconfig:"WAREHOUSE_SERVICE_RETRY_BACKOFF" default:"100ms"`
+	WarehouseMaxRetryBackoff time.Duration `envconfig:"WAREHOUSE_SERVICE_MAX_RETRY_BACKOFF" default:"5s"`
+	WarehouseDedupeTTL       time.Duration `envconfig:"WAREHOUSE_SERVICE_DEDUPE_TTL" default:"24h"`
+	RandomSeed               uint64        `envconfig:"RANDOM_SEED"`
+	ChaosConfig              string        `envconfig:"CHAOS_CONFIG"`
+	HealthCheckTimeout       time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+	ShutdownTimeout          time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s"`
 }
 
 func main() {
 	logger := slog.New(
-		slog.NewJSONHandler(os.Stdout, nil),
+		telemetry.SlogHandler("warehouse", slog.NewJSONHandler(os.Stdout, nil)),
 	)
 
 	cfg, err := config.Load[WarehouseConfig]()
@@ -27,27 +50,116 @@ func main() {
 		os.Exit(1)
 	}
 
-	storage := warehouse.NewRedisWarehouseStorage(logger, cfg.RedisAddress)
+	flushTelemetry, err := telemetry.Setup(context.Background(), "warehouse")
+	if err != nil {
+		logger.Error("setup telemetry", "error", err)
+		os.Exit(1)
+	}
+
+	injector := chaos.New(random.New(cfg.RandomSeed))
+	if cfg.ChaosConfig != "" {
+		if err := injector.Load(cfg.ChaosConfig); err != nil {
+			logger.Error("load chaos config", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	orders := order.NewRedisStore(cfg.RedisAddress)
+	storage := warehouse.NewRedisWarehouseStorage(logger, cfg.RedisAddress, orders, cfg.WarehouseDedupeTTL, injector.RedisHook())
 
-	warehouse, err := warehouse.NewKafkaRedisWarehouse(
+	deadLetters, err := warehouse.NewKafkaDeadLetterer(logger, cfg.KafkaBrokers, cfg.WarehouseDeadLetterTopic)
+	if err != nil {
+		logger.Error("failed to create dead-letterer", "error", err)
+		os.Exit(1)
+	}
+
+	kafkaWarehouse, err := warehouse.NewKafkaRedisWarehouse(
 		logger,
 		cfg.KafkaBrokers,
 		[]string{cfg.WarehouseTopic},
 		cfg.WarehouseTopic,
 		storage,
+		cfg.WarehouseBatchSize,
+		warehouse.RetryPolicy{
+			Retries:    cfg.WarehouseRetries,
+			Backoff:    cfg.WarehouseRetryBackoff,
+			MaxBackoff: cfg.WarehouseMaxRetryBackoff,
+		},
+		deadLetters,
+		injector,
 	)
 	if err != nil {
 		logger.Error("failed to create warehouse", "error", err)
 		os.Exit(1)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	kafkaChecker, err := kafka.NewMetadataChecker(cfg.KafkaBrokers, cfg.WarehouseTopic)
+	if err != nil {
+		logger.Error("failed to create Kafka health check", "error", err)
+		os.Exit(1)
+	}
 
-	for {
-		if err := warehouse.PickAndStore(ctx); err != nil {
-			logger.Error("failed to pick and store products", "error", err)
-			os.Exit(1)
+	checker := health.NewChecker(cfg.HealthCheckTimeout)
+	checker.Add("redis", storage.Ping)
+	checker.Add("kafka", kafkaChecker.Check)
+
+	mux := http.NewServeMux()
+	checker.Register(mux)
+	injector.Register(mux)
+
+	httpServer := &http.Server{
+		Addr:    cfg.WarehouseAddress,
+		Handler: telemetry.HTTPHandler(mux),
+	}
+
+	var steps shutdown.Sequence
+	steps.Add("http server", httpServer.Shutdown)
+	steps.AddCloser("consumer group", kafkaWarehouse)
+	steps.AddCloser("dead-letterer", deadLetters)
+	steps.AddCloser("storage", storage)
+	steps.AddCloser("orders", orders)
+	steps.AddCloser("Kafka health check", kafkaChecker)
+	steps.Add("telemetry", flushTelemetry)
+
+	ctx, stop := shutdown.NotifyContext(context.Background())
+	defer stop()
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		err := httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server failed", "error", err)
+			return err
+		}
+
+		return nil
+	})
+
+	g.Go(func() error {
+		for ctx.Err() == nil {
+			err := kafkaWarehouse.PickAndStore(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error("failed to pick and store products", "error", err)
+				return err
+			}
 		}
+
+		return nil
+	})
+
+	g.Go(func() error {
+		<-ctx.Done()
+		logger.Info("shutting down warehouse service")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		return steps.Run(shutdownCtx)
+	})
+
+	if err := g.Wait(); err != nil {
+		logger.Error("warehouse failed", "error", err)
+		os.Exit(1)
 	}
 }
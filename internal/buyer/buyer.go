@@ -2,103 +2,292 @@ package buyer
 
 import (
 	"context"
+	"time"
+
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/customer"
+	"vinted/otel-workshop/internal/health"
 	"vinted/otel-workshop/internal/random"
 	"vinted/otel-workshop/pb/genproto/otelworkshop"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type Buyer interface {
 	Buy(context.Context) error
 }
 
-type RandomBuyer struct {
-	client otelworkshop.ShopServiceClient
-	logger *logrus.Logger
+// ShopBuyer buys products from the shop over gRPC. Buy picks a random
+// product and quantity; BuyWith lets strategies choose instead. Quantities
+// never exceed the catalog's MaxOrderQuantity.
+type ShopBuyer struct {
+	conn      *grpc.ClientConn
+	client    otelworkshop.ShopServiceClient
+	catalog   catalog.Catalog
+	random    *random.Source
+	customers []*customer.Customer
+	logger    *logrus.Logger
 }
 
-func NewRandomBuyer(logger *logrus.Logger, shopAddress string) (*RandomBuyer, error) {
-	conn, err := grpc.NewClient(shopAddress,
+// customerPoolSize is how many customers a ShopBuyer buys on behalf of.
+const customerPoolSize = 50
+
+func NewShopBuyer(logger *logrus.Logger, shopAddress string, cat catalog.Catalog, rnd *random.Source, opts ...grpc.DialOption) (*ShopBuyer, error) {
+	opts = append([]grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	}, opts...)
+
+	conn, err := grpc.NewClient(shopAddress, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	client := otelworkshop.NewShopServiceClient(conn)
 
-	return &RandomBuyer{
-		client: client,
-		logger: logger,
+	return &ShopBuyer{
+		conn:      conn,
+		client:    client,
+		catalog:   cat,
+		random:    rnd,
+		customers: customer.NewPool(rnd, customerPoolSize),
+		logger:    logger,
 	}, nil
 }
 
-var names = []string{
-	"John",
-	"Jane",
-	"Jack",
-	"Jill",
-	"James",
+// Ping checks that the shop is reachable and serving.
+func (b *ShopBuyer) Ping(ctx context.Context) error {
+	return health.CheckGRPC(ctx, b.conn)
 }
 
-var surnames = []string{
-	"Doe",
-	"Smith",
-	"Johnson",
-	"Brown",
-	"Williams",
+func (b *ShopBuyer) Close() error {
+	return b.conn.Close()
 }
 
-type person struct {
-	name    string
-	surname string
+// pickCustomer picks the customer making the next purchase and returns ctx
+// carrying their ID as baggage, so that the shop can tell customers apart.
+func (b *ShopBuyer) pickCustomer(ctx context.Context) (context.Context, *customer.Customer) {
+	c := random.Item(b.random, b.customers)
+
+	ctx, err := customer.ContextWithID(ctx, c.ID)
+	if err != nil {
+		b.logger.WithContext(ctx).WithError(err).Warn("failed to add customer to baggage")
+	}
+
+	return ctx, c
+}
+
+func (b *ShopBuyer) Buy(ctx context.Context) error {
+	return b.BuyWith(ctx, PickRandom)
 }
 
-func randomPerson() *person {
-	return &person{
-		name:    random.Item(names),
-		surname: random.Item(surnames),
+func (b *ShopBuyer) inStock(ctx context.Context) ([]*otelworkshop.Product, error) {
+	resp, err := b.client.ListProducts(ctx, &otelworkshop.ListProductsRequest{
+		Filter: &otelworkshop.ProductFilter{MinQuantity: proto.Int64(1)},
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	b.logger.WithContext(ctx).WithField("count", len(resp.Products)).Info("listed products")
+
+	return resp.Products, nil
 }
 
-func (b *RandomBuyer) Buy(ctx context.Context) error {
-	resp, err := b.client.ListProducts(ctx, &otelworkshop.Empty{})
+// pick chooses among products with pick and caps the quantity at the
+// catalog's MaxOrderQuantity, which the shop would reject.
+func (b *ShopBuyer) pick(ctx context.Context, pick Picker, products []*otelworkshop.Product) (*otelworkshop.Product, int64) {
+	product, quantity := pick(b.random, products)
+
+	item, err := b.catalog.Item(ctx, product.Name)
 	if err != nil {
-		return err
+		b.logger.WithContext(ctx).WithField("product", product.Name).WithError(err).Warn("failed to look up max order quantity")
+		return product, quantity
 	}
 
-	b.logger.WithField("count", len(resp.Products)).Info("listed products")
+	return product, min(quantity, item.MaxOrderQuantity)
+}
 
-	if len(resp.Products) == 0 {
-		return nil
+// BuyWith lists the products in stock and buys the one chosen by pick.
+func (b *ShopBuyer) BuyWith(ctx context.Context, pick Picker) error {
+	ctx, person := b.pickCustomer(ctx)
+
+	products, err := b.inStock(ctx)
+	if err != nil || len(products) == 0 {
+		return err
 	}
 
-	product := random.Item(resp.Products)
-	person := randomPerson()
-	quantity := random.Int64(product.Quantity)
+	product, quantity := b.pick(ctx, pick, products)
 
 	_, err = b.client.BuyProduct(ctx, &otelworkshop.BuyProductRequest{
-		Name:    person.name,
-		Surname: person.surname,
+		Name:    person.Name,
+		Surname: person.Surname,
 		Product: &otelworkshop.Product{
 			Name:     product.Name,
 			Color:    product.Color,
 			Quantity: quantity,
 		},
 	})
+	if status.Code(err) == codes.FailedPrecondition {
+		b.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"quantity": quantity,
+			"color":    product.Color,
+			"product":  product.Name,
+		}).WithError(err).Warn("product out of stock")
+		return nil
+	}
+	if status.Code(err) == codes.ResourceExhausted {
+		b.logger.WithContext(ctx).WithField("customer_id", person.ID).WithError(err).Warn("customer rate limited")
+		return nil
+	}
+	if status.Code(err) == codes.InvalidArgument {
+		b.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"quantity": quantity,
+			"color":    product.Color,
+			"product":  product.Name,
+		}).WithError(err).Warn("shop rejected purchase")
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	b.logger.WithFields(logrus.Fields{
-		"name":     person.name,
-		"surname":  person.surname,
-		"quantity": quantity,
-		"color":    product.Color,
-		"product":  product.Name,
+	b.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"customer_id": person.ID,
+		"name":        person.Name,
+		"surname":     person.Surname,
+		"quantity":    quantity,
+		"color":       product.Color,
+		"product":     product.Name,
 	}).Info("bought product")
 
 	return nil
 }
+
+// CheckoutWith lists the products in stock and checks out a cart of up to
+// maxLines lines, each chosen by pick.
+func (b *ShopBuyer) CheckoutWith(ctx context.Context, pick Picker, maxLines int) error {
+	ctx, person := b.pickCustomer(ctx)
+
+	products, err := b.inStock(ctx)
+	if err != nil || len(products) == 0 {
+		return err
+	}
+
+	lines := make([]*otelworkshop.Product, 1+b.random.Int(maxLines))
+	for i := range lines {
+		product, quantity := b.pick(ctx, pick, products)
+		lines[i] = &otelworkshop.Product{
+			Name:     product.Name,
+			Color:    product.Color,
+			Quantity: quantity,
+		}
+	}
+
+	resp, err := b.client.Checkout(ctx, &otelworkshop.CheckoutRequest{
+		Name:    person.Name,
+		Surname: person.Surname,
+		Lines:   lines,
+	})
+	if code := status.Code(err); code == codes.FailedPrecondition || code == codes.InvalidArgument || code == codes.ResourceExhausted {
+		b.logger.WithContext(ctx).WithField("lines", len(lines)).WithError(err).Warn("shop rejected checkout")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	b.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"customer_id": person.ID,
+		"name":        person.Name,
+		"surname":     person.Surname,
+		"lines":       len(lines),
+		"total":       resp.Total,
+		"currency":    resp.Currency,
+	}).Info("checked out")
+
+	return nil
+}
+
+// ReserveWith reserves the product chosen by pick and, after thinking for up
+// to think, confirms the reservation. With probability abandonRate the buyer
+// changes their mind instead: half of the time they cancel the reservation
+// and otherwise they walk away and leave it to expire.
+func (b *ShopBuyer) ReserveWith(ctx context.Context, pick Picker, abandonRate float64, think time.Duration) error {
+	ctx, person := b.pickCustomer(ctx)
+
+	products, err := b.inStock(ctx)
+	if err != nil || len(products) == 0 {
+		return err
+	}
+
+	product, quantity := b.pick(ctx, pick, products)
+
+	reservation, err := b.client.ReserveProduct(ctx, &otelworkshop.ReserveProductRequest{
+		Name:    person.Name,
+		Surname: person.Surname,
+		Product: &otelworkshop.Product{
+			Name:     product.Name,
+			Color:    product.Color,
+			Quantity: quantity,
+		},
+	})
+	if code := status.Code(err); code == codes.FailedPrecondition || code == codes.InvalidArgument || code == codes.ResourceExhausted {
+		b.logger.WithContext(ctx).WithFields(logrus.Fields{
+			"quantity": quantity,
+			"color":    product.Color,
+			"product":  product.Name,
+		}).WithError(err).Warn("shop rejected reservation")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logger := b.logger.WithContext(ctx).WithField("reservation_id", reservation.Id)
+
+	select {
+	case <-time.After(time.Duration(b.random.Float64() * float64(think))):
+	case <-ctx.Done():
+		return nil
+	}
+
+	if b.random.Float64() < abandonRate {
+		if b.random.Float64() < 0.5 {
+			logger.Info("abandoned reservation")
+			return nil
+		}
+
+		_, err = b.client.CancelReservation(ctx, &otelworkshop.CancelReservationRequest{Id: reservation.Id})
+		if err != nil {
+			return err
+		}
+
+		logger.Info("cancelled reservation")
+		return nil
+	}
+
+	_, err = b.client.ConfirmReservation(ctx, &otelworkshop.ConfirmReservationRequest{Id: reservation.Id})
+	if code := status.Code(err); code == codes.FailedPrecondition || code == codes.NotFound {
+		logger.WithError(err).Warn("reservation lapsed")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"customer_id": person.ID,
+		"name":        person.Name,
+		"surname":     person.Surname,
+		"quantity":    quantity,
+		"color":       product.Color,
+		"product":     product.Name,
+	}).Info("confirmed reservation")
+
+	return nil
+}
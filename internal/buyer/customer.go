@@ -0,0 +1,65 @@
+package buyer
+
+import (
+	"net/http"
+	"strconv"
+
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+type customerJSON struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Surname string `json:"surname"`
+}
+
+// HandleListCustomers lists the customers the buyer buys on behalf of.
+func (b *ShopBuyer) HandleListCustomers(w http.ResponseWriter, _ *http.Request) {
+	customers := make([]customerJSON, len(b.customers))
+	for i, c := range b.customers {
+		customers[i] = customerJSON{ID: c.ID, Name: c.Name, Surname: c.Surname}
+	}
+
+	writeJSON(w, http.StatusOK, customers)
+}
+
+// HandlePurchaseHistory returns the customer's purchase history from the
+// shop, newest first, optionally capped by the limit query parameter.
+func (b *ShopBuyer) HandlePurchaseHistory(w http.ResponseWriter, r *http.Request) {
+	var limit int64
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	history, err := b.client.GetPurchaseHistory(r.Context(), &otelworkshop.GetPurchaseHistoryRequest{
+		CustomerId: r.PathValue("id"),
+		Limit:      int32(limit),
+	})
+	if status.Code(err) == codes.InvalidArgument {
+		http.Error(w, status.Convert(err).Message(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		b.logger.WithContext(r.Context()).WithError(err).Error("failed to get purchase history")
+		http.Error(w, status.Convert(err).Message(), http.StatusBadGateway)
+		return
+	}
+
+	body, err := protojson.Marshal(history)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,175 @@
+package buyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Phase describes traffic sustained for Duration: RPS buys per second spread
+// over Concurrency workers, each buying with Strategy.
+type Phase struct {
+	Name        string        `yaml:"name"`
+	Duration    time.Duration `yaml:"duration"`
+	RPS         float64       `yaml:"rps"`
+	Concurrency int           `yaml:"concurrency"`
+	Strategy    string        `yaml:"strategy"`
+}
+
+// MaxRPS is the highest rate a phase can request: requests are paced by a
+// ticker, which can't fire more often than every nanosecond.
+const MaxRPS = float64(time.Second)
+
+type Scenario struct {
+	Name   string  `yaml:"name"`
+	Phases []Phase `yaml:"phases"`
+}
+
+// LoadScenario reads a scenario from a YAML or JSON file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is valid YAML, so a single decoder handles both.
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+
+	if err := scenario.validate(); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", path, err)
+	}
+
+	return &scenario, nil
+}
+
+func (s *Scenario) validate() error {
+	if len(s.Phases) == 0 {
+		return errors.New("no phases")
+	}
+
+	for i := range s.Phases {
+		phase := &s.Phases[i]
+		if phase.Name == "" {
+			phase.Name = fmt.Sprintf("phase-%d", i+1)
+		}
+		if phase.Concurrency == 0 {
+			phase.Concurrency = 1
+		}
+		if phase.Strategy == "" {
+			phase.Strategy = StrategyRandom
+		}
+
+		switch {
+		case phase.Duration <= 0:
+			return fmt.Errorf("phase %s: duration must be positive", phase.Name)
+		case phase.RPS <= 0:
+			return fmt.Errorf("phase %s: rps must be positive", phase.Name)
+		case phase.RPS > MaxRPS:
+			return fmt.Errorf("phase %s: rps must not exceed %g", phase.Name, MaxRPS)
+		case phase.Concurrency < 0:
+			return fmt.Errorf("phase %s: concurrency must be positive", phase.Name)
+		case !slices.Contains(Strategies, phase.Strategy):
+			return fmt.Errorf("phase %s: unknown strategy %q", phase.Name, phase.Strategy)
+		}
+	}
+
+	return nil
+}
+
+// Run plays the phases in order against shop until they are all done or
+// ctx is cancelled. Failed buys are logged and do not stop the scenario.
+func (s *Scenario) Run(ctx context.Context, logger *logrus.Logger, shop *ShopBuyer) error {
+	for _, phase := range s.Phases {
+		if err := s.runPhase(ctx, logger, shop, phase); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Scenario) runPhase(ctx context.Context, logger *logrus.Logger, shop *ShopBuyer, phase Phase) error {
+	buyer, err := NewStrategy(phase.Strategy, shop)
+	if err != nil {
+		return err
+	}
+
+	log := logger.WithFields(logrus.Fields{
+		"scenario":    s.Name,
+		"phase":       phase.Name,
+		"strategy":    phase.Strategy,
+		"rps":         phase.RPS,
+		"concurrency": phase.Concurrency,
+		"duration":    phase.Duration,
+	})
+	log.Info("starting scenario phase")
+
+	phaseCtx, cancel := context.WithTimeout(ctx, phase.Duration)
+	defer cancel()
+
+	var bought, failed, skipped atomic.Int64
+
+	// Requests are issued at a fixed rate. When every worker is busy the
+	// request is skipped rather than queued, so a slow shop shows up as
+	// skipped requests instead of a growing backlog.
+	requests := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < phase.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for range requests {
+				if err := buyer.Buy(phaseCtx); err != nil {
+					if phaseCtx.Err() == nil {
+						log.WithContext(phaseCtx).WithError(err).Warn("failed to buy")
+						failed.Add(1)
+					}
+					continue
+				}
+				bought.Add(1)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / phase.RPS))
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-ticker.C:
+		case <-phaseCtx.Done():
+			break loop
+		}
+
+		select {
+		case requests <- struct{}{}:
+		default:
+			skipped.Add(1)
+		}
+	}
+
+	close(requests)
+	wg.Wait()
+
+	log.WithFields(logrus.Fields{
+		"bought":  bought.Load(),
+		"failed":  failed.Load(),
+		"skipped": skipped.Load(),
+	}).Info("finished scenario phase")
+
+	return ctx.Err()
+}
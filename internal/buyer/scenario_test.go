@@ -0,0 +1,31 @@
+package buyer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScenarioValidateRPS(t *testing.T) {
+	tests := []struct {
+		rps     float64
+		wantErr string
+	}{
+		{rps: 0.5},
+		{rps: MaxRPS},
+		{rps: 0, wantErr: "rps must be positive"},
+		{rps: 2e9, wantErr: "rps must not exceed"},
+	}
+
+	for _, tt := range tests {
+		scenario := Scenario{Phases: []Phase{{Duration: time.Second, RPS: tt.rps}}}
+
+		err := scenario.validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("rps %g: unexpected error %v", tt.rps, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("rps %g: err = %v, want %q", tt.rps, err, tt.wantErr)
+		}
+	}
+}
@@ -3,24 +3,47 @@ package buyer
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/order"
+	"vinted/otel-workshop/internal/product"
 	"vinted/otel-workshop/pb/genproto/otelworkshop"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
 )
 
+var meter = otel.Meter("vinted/otel-workshop/internal/buyer")
+
 type BuyerServer struct {
 	logger      *logrus.Logger
 	factoryAddr string
 	client      http.Client
+	orders      order.Store
+	catalog     catalog.Catalog
+	ordered     metric.Int64Counter
 }
 
-func NewBuyerServer(logger *logrus.Logger, factoryAddr string, client http.Client) *BuyerServer {
+func NewBuyerServer(logger *logrus.Logger, factoryAddr string, client http.Client, orders order.Store, cat catalog.Catalog) *BuyerServer {
+	ordered, err := meter.Int64Counter("workshop.products.ordered",
+		metric.WithDescription("Number of products ordered from the factory."),
+		metric.WithUnit("{product}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
 	return &BuyerServer{
 		logger:      logger,
 		factoryAddr: factoryAddr,
 		client:      client,
+		orders:      orders,
+		catalog:     cat,
+		ordered:     ordered,
 	}
 }
 
@@ -33,41 +56,128 @@ func (s *BuyerServer) HandleOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.logger.WithFields(logrus.Fields{
+	_, err = catalog.Validate(r.Context(), s.catalog, &p)
+	var invalid *catalog.ValidationError
+	if errors.As(err, &invalid) {
+		s.logger.WithContext(r.Context()).WithError(invalid).Warn("rejected invalid order")
+		writeJSON(w, http.StatusBadRequest, invalid)
+		return
+	}
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Error("failed to validate order")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	o := order.New(&p)
+	p.OrderId = o.ID
+
+	logger := s.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"order_id": o.ID,
 		"name":     p.Name,
 		"color":    p.Color,
 		"quantity": p.Quantity,
-	}).Info("received order")
+	})
+	logger.Info("received order")
+
+	err = s.orders.Create(r.Context(), o)
+	if err != nil {
+		logger.WithError(err).Error("failed to create order")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	url := fmt.Sprintf("http://%s/make", s.factoryAddr)
 
-	order, err := json.Marshal(&p)
+	body, err := json.Marshal(&p)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	proxyReq, err := http.NewRequest(r.Method, url, bytes.NewBuffer(order))
+	proxyReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, url, bytes.NewBuffer(body))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	proxyReq.Header.Set("Host", r.Host)
+	proxyReq.Header.Set("Content-Type", "application/json")
 	proxyReq.Header.Set("X-Forwarded-For", r.RemoteAddr)
+	proxyReq.Header.Set("X-Forwarded-Host", r.Host)
 
-	for header, values := range r.Header {
-		for _, value := range values {
-			proxyReq.Header.Add(header, value)
-		}
+	resp, err := s.client.Do(proxyReq)
+	if err != nil {
+		logger.WithError(err).Error("failed to call factory")
+		s.failOrder(r, o)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
 	}
+	defer resp.Body.Close()
 
-	resp, err := s.client.Do(proxyReq)
+	// The factory may run with a different catalog, so its verdict is
+	// passed on as is.
+	if resp.StatusCode == http.StatusBadRequest {
+		logger.Warn("factory rejected invalid order")
+		s.failOrder(r, o)
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+		return
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		logger.WithField("status", resp.StatusCode).Error("factory rejected order")
+		s.failOrder(r, o)
+		http.Error(w, fmt.Sprintf("factory responded with %s", resp.Status), http.StatusBadGateway)
+		return
+	}
+
+	// The factory only queues the order; its workers advance it from here.
+	s.ordered.Add(r.Context(), p.Quantity, metric.WithAttributes(product.Attributes(&p)...))
+
+	o, err = s.orders.Get(r.Context(), o.ID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Location", "/orders/"+o.ID)
+	writeJSON(w, http.StatusAccepted, o)
+}
+
+func (s *BuyerServer) HandleGetOrder(w http.ResponseWriter, r *http.Request) {
+	o, err := s.orders.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, order.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+func (s *BuyerServer) HandleListOrders(w http.ResponseWriter, r *http.Request) {
+	orders, err := s.orders.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func (s *BuyerServer) failOrder(r *http.Request, o *order.Order) {
+	err := s.orders.Advance(r.Context(), o.ID, order.StateFailed)
+	if err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).WithField("order_id", o.ID).Error("failed to mark order as failed")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
 }
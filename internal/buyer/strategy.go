@@ -0,0 +1,173 @@
+package buyer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+)
+
+const (
+	StrategyRandom  = "random"
+	StrategyPopular = "popular"
+	StrategyGreedy  = "greedy"
+	StrategyBursty  = "bursty"
+	StrategyDiurnal = "diurnal"
+	StrategyCart    = "cart"
+	StrategyReserve = "reserve"
+)
+
+var Strategies = []string{StrategyRandom, StrategyPopular, StrategyGreedy, StrategyBursty, StrategyDiurnal, StrategyCart, StrategyReserve}
+
+// NewStrategy returns a Buyer that buys from shop following the named
+// strategy.
+func NewStrategy(name string, shop *ShopBuyer) (Buyer, error) {
+	switch name {
+	case StrategyRandom:
+		return shop, nil
+	case StrategyPopular:
+		return pickingBuyer(shop, PickPopular), nil
+	case StrategyGreedy:
+		return pickingBuyer(shop, PickGreedy), nil
+	case StrategyBursty:
+		return NewBurstyBuyer(shop, shop.random, 0.1, 10), nil
+	case StrategyDiurnal:
+		return NewDiurnalBuyer(shop, shop.random, 10*time.Minute, 0.1), nil
+	case StrategyCart:
+		return BuyerFunc(func(ctx context.Context) error {
+			return shop.CheckoutWith(ctx, PickPopular, 3)
+		}), nil
+	case StrategyReserve:
+		return BuyerFunc(func(ctx context.Context) error {
+			return shop.ReserveWith(ctx, PickRandom, 0.3, 2*time.Second)
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown buyer strategy %q", name)
+	}
+}
+
+type BuyerFunc func(context.Context) error
+
+func (f BuyerFunc) Buy(ctx context.Context) error {
+	return f(ctx)
+}
+
+func pickingBuyer(shop *ShopBuyer, pick Picker) Buyer {
+	return BuyerFunc(func(ctx context.Context) error {
+		return shop.BuyWith(ctx, pick)
+	})
+}
+
+// Picker chooses what to buy among products that are in stock.
+type Picker func(rnd *random.Source, products []*otelworkshop.Product) (product *otelworkshop.Product, quantity int64)
+
+// PickRandom picks a uniformly random product and a quantity of at least one
+// and at most its stock.
+func PickRandom(rnd *random.Source, products []*otelworkshop.Product) (*otelworkshop.Product, int64) {
+	product := random.Item(rnd, products)
+	return product, 1 + rnd.Int64(product.Quantity)
+}
+
+// PickPopular favours products listed first: the n-th product is picked
+// with a weight of 1/n, as in a Zipf distribution.
+func PickPopular(rnd *random.Source, products []*otelworkshop.Product) (*otelworkshop.Product, int64) {
+	var total float64
+	for i := range products {
+		total += 1 / float64(i+1)
+	}
+
+	target := rnd.Float64() * total
+	product := products[len(products)-1]
+	for i, p := range products {
+		target -= 1 / float64(i+1)
+		if target < 0 {
+			product = p
+			break
+		}
+	}
+
+	return product, 1 + rnd.Int64(product.Quantity)
+}
+
+// PickGreedy buys out the product with the most stock. ShopBuyer caps the
+// quantity at the catalog's MaxOrderQuantity.
+func PickGreedy(_ *random.Source, products []*otelworkshop.Product) (*otelworkshop.Product, int64) {
+	product := products[0]
+	for _, p := range products[1:] {
+		if p.Quantity > product.Quantity {
+			product = p
+		}
+	}
+
+	return product, product.Quantity
+}
+
+// BurstyBuyer usually buys once, but with probability chance it buys size
+// times in a row.
+type BurstyBuyer struct {
+	Buyer
+	random *random.Source
+	chance float64
+	size   int
+}
+
+func NewBurstyBuyer(buyer Buyer, rnd *random.Source, chance float64, size int) *BurstyBuyer {
+	return &BurstyBuyer{
+		Buyer:  buyer,
+		random: rnd,
+		chance: chance,
+		size:   size,
+	}
+}
+
+func (b *BurstyBuyer) Buy(ctx context.Context) error {
+	n := 1
+	if b.random.Float64() < b.chance {
+		n = b.size
+	}
+
+	for i := 0; i < n; i++ {
+		if err := b.Buyer.Buy(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DiurnalBuyer follows a day compressed into period: it starts at night,
+// when only a min fraction of calls to Buy go through, and peaks halfway
+// through the period, when all of them do.
+type DiurnalBuyer struct {
+	Buyer
+	random *random.Source
+	period time.Duration
+	min    float64
+	start  time.Time
+}
+
+func NewDiurnalBuyer(buyer Buyer, rnd *random.Source, period time.Duration, min float64) *DiurnalBuyer {
+	return &DiurnalBuyer{
+		Buyer:  buyer,
+		random: rnd,
+		period: period,
+		min:    min,
+		start:  time.Now(),
+	}
+}
+
+func (b *DiurnalBuyer) Buy(ctx context.Context) error {
+	if b.random.Float64() >= b.activity(time.Since(b.start)) {
+		return nil
+	}
+
+	return b.Buyer.Buy(ctx)
+}
+
+func (b *DiurnalBuyer) activity(elapsed time.Duration) float64 {
+	phase := 2 * math.Pi * float64(elapsed%b.period) / float64(b.period)
+	return b.min + (1-b.min)*(1-math.Cos(phase))/2
+}
@@ -0,0 +1,89 @@
+package buyer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/sirupsen/logrus"
+)
+
+var stock = []*otelworkshop.Product{
+	{Name: "shoes", Color: "red", Quantity: 12},
+	{Name: "hat", Color: "blue", Quantity: 3},
+	{Name: "socks", Color: "green", Quantity: 40},
+	{Name: "pants", Color: "black", Quantity: 7},
+}
+
+// picks runs pick n times and records what it chose.
+func picks(rnd *random.Source, pick Picker, n int) []string {
+	var got []string
+	for range n {
+		p, quantity := pick(rnd, stock)
+		got = append(got, fmt.Sprintf("%s %s x%d", p.Color, p.Name, quantity))
+	}
+
+	return got
+}
+
+func TestPickersAreDeterministicPerSeed(t *testing.T) {
+	pickers := map[string]Picker{
+		StrategyRandom:  PickRandom,
+		StrategyPopular: PickPopular,
+	}
+
+	for name, pick := range pickers {
+		a := picks(random.New(42), pick, 100)
+		b := picks(random.New(42), pick, 100)
+		if !slices.Equal(a, b) {
+			t.Errorf("%s: same seed picked differently:\n%v\n%v", name, a, b)
+		}
+
+		if c := picks(random.New(43), pick, 100); slices.Equal(a, c) {
+			t.Errorf("%s: different seeds picked the same", name)
+		}
+	}
+}
+
+func TestBurstyBuyerIsDeterministicPerSeed(t *testing.T) {
+	buys := func(seed uint64) []int {
+		var count int
+		buyer := NewBurstyBuyer(BuyerFunc(func(context.Context) error {
+			count++
+			return nil
+		}), random.New(seed), 0.3, 5)
+
+		var got []int
+		for range 50 {
+			count = 0
+			if err := buyer.Buy(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, count)
+		}
+
+		return got
+	}
+
+	if a, b := buys(7), buys(7); !slices.Equal(a, b) {
+		t.Errorf("same seed bought differently:\n%v\n%v", a, b)
+	}
+}
+
+func TestPickCapsQuantityAtMaxOrderQuantity(t *testing.T) {
+	buyer := &ShopBuyer{
+		catalog: catalog.NewMemoryCatalog(catalog.Item{Name: "socks", Colors: []string{"green"}, MaxOrderQuantity: 10}),
+		random:  random.New(1),
+		logger:  logrus.New(),
+	}
+
+	p, quantity := buyer.pick(context.Background(), PickGreedy, stock)
+	if p.Name != "socks" || quantity != 10 {
+		t.Errorf("picked %d %s, want 10 socks", quantity, p.Name)
+	}
+}
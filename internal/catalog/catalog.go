@@ -0,0 +1,170 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"vinted/otel-workshop/internal/product"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	BackendFile  = "file"
+	BackendRedis = "redis"
+)
+
+var ErrNotFound = errors.New("product not in catalog")
+
+// Item is a product the workshop sells. Price is in minor units of Currency.
+type Item struct {
+	SKU              string   `json:"sku" yaml:"sku"`
+	Name             string   `json:"name" yaml:"name"`
+	Colors           []string `json:"colors" yaml:"colors"`
+	MaxOrderQuantity int64    `json:"max_order_quantity" yaml:"max_order_quantity"`
+	Price            int64    `json:"price" yaml:"price"`
+	Currency         string   `json:"currency" yaml:"currency"`
+}
+
+func (i Item) validate() error {
+	switch {
+	case i.Name == "":
+		return errors.New("name is required")
+	case i.SKU == "":
+		return fmt.Errorf("%s: sku is required", i.Name)
+	case len(i.Colors) == 0:
+		return fmt.Errorf("%s: at least one color is required", i.Name)
+	case i.MaxOrderQuantity < 1:
+		return fmt.Errorf("%s: max_order_quantity must be positive", i.Name)
+	case i.Price < 0:
+		return fmt.Errorf("%s: price must not be negative", i.Name)
+	case i.Currency == "":
+		return fmt.Errorf("%s: currency is required", i.Name)
+	}
+
+	return nil
+}
+
+// Catalog lists the products that may be made, ordered and bought.
+type Catalog interface {
+	// Items returns every item, sorted by name.
+	Items(ctx context.Context) ([]Item, error)
+	// Item returns the item called name or ErrNotFound.
+	Item(ctx context.Context, name string) (Item, error)
+	Close() error
+}
+
+var defaultPrices = map[string]int64{
+	product.NameShoes: 7999,
+	product.NameHat:   1999,
+	product.NameSocks: 499,
+	product.NamePants: 4999,
+	product.NameShirt: 2999,
+}
+
+// Default returns the built-in catalog: every product name in every color.
+func Default() []Item {
+	items := make([]Item, 0, len(product.Names()))
+	for _, name := range product.Names() {
+		items = append(items, Item{
+			SKU:              strings.ToUpper(name),
+			Name:             name,
+			Colors:           product.Colors(),
+			MaxOrderQuantity: 10000,
+			Price:            defaultPrices[name],
+			Currency:         "EUR",
+		})
+	}
+
+	return items
+}
+
+// Load reads catalog items from a YAML or JSON file.
+func Load(path string) ([]Item, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	if err := yaml.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("parse catalog %s: %w", path, err)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("catalog %s: no items", path)
+	}
+
+	names := make(map[string]bool, len(items))
+	for _, item := range items {
+		if err := item.validate(); err != nil {
+			return nil, fmt.Errorf("catalog %s: %w", path, err)
+		}
+		if names[item.Name] {
+			return nil, fmt.Errorf("catalog %s: duplicate item %s", path, item.Name)
+		}
+		names[item.Name] = true
+	}
+
+	return items, nil
+}
+
+// Open returns the catalog for backend. Items come from the file at path,
+// or Default if path is empty. The Redis backend only uses them to seed
+// items missing from Redis, so that the catalog can be edited at runtime.
+func Open(ctx context.Context, backend, path, redisAddr string) (Catalog, error) {
+	items := Default()
+	if path != "" {
+		var err error
+		items, err = Load(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if backend != BackendRedis {
+		return NewMemoryCatalog(items...), nil
+	}
+
+	c := NewRedisCatalog(redisAddr)
+	if err := c.Seed(ctx, items); err != nil {
+		return nil, errors.Join(fmt.Errorf("seed catalog: %w", err), c.Close())
+	}
+
+	return c, nil
+}
+
+type MemoryCatalog struct {
+	items []Item
+}
+
+func NewMemoryCatalog(items ...Item) *MemoryCatalog {
+	items = slices.Clone(items)
+	slices.SortFunc(items, func(a, b Item) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return &MemoryCatalog{items: items}
+}
+
+func (c *MemoryCatalog) Items(context.Context) ([]Item, error) {
+	return c.items, nil
+}
+
+func (c *MemoryCatalog) Item(_ context.Context, name string) (Item, error) {
+	for _, item := range c.items {
+		if item.Name == name {
+			return item, nil
+		}
+	}
+
+	return Item{}, ErrNotFound
+}
+
+func (c *MemoryCatalog) Close() error {
+	return nil
+}
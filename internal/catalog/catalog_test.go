@@ -0,0 +1,96 @@
+package catalog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func writeCatalog(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "catalog.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+const hatCatalog = `
+- sku: HAT
+  name: hat
+  colors: [red, blue]
+  max_order_quantity: 5
+  price: 1999
+  currency: EUR
+`
+
+func TestLoad(t *testing.T) {
+	items, err := Load(writeCatalog(t, hatCatalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "hat" || items[0].MaxOrderQuantity != 5 || len(items[0].Colors) != 2 {
+		t.Errorf("items = %v, want the hat", items)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "[]", "no items"},
+		{"not yaml", "{", "parse catalog"},
+		{"no colors", strings.Replace(hatCatalog, "[red, blue]", "[]", 1), "at least one color"},
+		{"negative max quantity", strings.Replace(hatCatalog, "max_order_quantity: 5", "max_order_quantity: -1", 1), "max_order_quantity"},
+		{"duplicate", hatCatalog + hatCatalog, "duplicate item hat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeCatalog(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	path := writeCatalog(t, hatCatalog)
+
+	memory, err := Open(ctx, BackendFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := memory.Items(ctx); len(items) != len(Default()) {
+		t.Errorf("default catalog has %d items, want %d", len(items), len(Default()))
+	}
+
+	file, err := Open(ctx, BackendFile, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item, err := file.Item(ctx, "hat"); err != nil || item.MaxOrderQuantity != 5 {
+		t.Errorf("hat = %v, %v; want it from the file", item, err)
+	}
+
+	redis, err := Open(ctx, BackendRedis, path, miniredis.RunT(t).Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redis.Close()
+	if items, err := redis.Items(ctx); err != nil || len(items) != 1 || items[0].Name != "hat" {
+		t.Errorf("items = %v, %v; want the seeded hat", items, err)
+	}
+
+	if _, err := Open(ctx, BackendFile, filepath.Join(t.TempDir(), "missing.yaml"), ""); err == nil {
+		t.Error("opened a missing catalog file")
+	}
+}
@@ -0,0 +1,101 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// itemsKey is a hash of JSON encoded items by name.
+const itemsKey = "catalog:items"
+
+type RedisCatalog struct {
+	client *redis.Client
+}
+
+func NewRedisCatalog(redisAddr string) *RedisCatalog {
+	return &RedisCatalog{
+		client: redis.NewClient(&redis.Options{
+			Addr: redisAddr,
+		}),
+	}
+}
+
+func (c *RedisCatalog) Close() error {
+	return c.client.Close()
+}
+
+func (c *RedisCatalog) Items(ctx context.Context) ([]Item, error) {
+	hash, err := c.client.HGetAll(ctx, itemsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(hash))
+	for name, value := range hash {
+		item, err := decodeItem(name, value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	slices.SortFunc(items, func(a, b Item) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return items, nil
+}
+
+func (c *RedisCatalog) Item(ctx context.Context, name string) (Item, error) {
+	value, err := c.client.HGet(ctx, itemsKey, name).Result()
+	if errors.Is(err, redis.Nil) {
+		return Item{}, ErrNotFound
+	}
+	if err != nil {
+		return Item{}, err
+	}
+
+	return decodeItem(name, value)
+}
+
+// decodeItem decodes the item stored under name and checks it the way Load
+// checks files, since items in Redis may be edited by hand.
+func decodeItem(name, value string) (Item, error) {
+	var item Item
+	if err := json.Unmarshal([]byte(value), &item); err != nil {
+		return Item{}, fmt.Errorf("decode catalog item %s: %w", name, err)
+	}
+
+	if err := item.validate(); err != nil {
+		return Item{}, fmt.Errorf("catalog item %s: %w", name, err)
+	}
+	if item.Name != name {
+		return Item{}, fmt.Errorf("catalog item %s: stored as %s", item.Name, name)
+	}
+
+	return item, nil
+}
+
+// Seed adds the items that are not in Redis yet and leaves the rest as they
+// are.
+func (c *RedisCatalog) Seed(ctx context.Context, items []Item) error {
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, item := range items {
+			value, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			pipe.HSetNX(ctx, itemsKey, item.Name, value)
+		}
+
+		return nil
+	})
+
+	return err
+}
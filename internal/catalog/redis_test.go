@@ -0,0 +1,92 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+var hat = Item{SKU: "HAT", Name: "hat", Colors: []string{"red", "blue"}, MaxOrderQuantity: 5, Price: 1999, Currency: "EUR"}
+
+func newTestRedisCatalog(t *testing.T) (*RedisCatalog, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	c := NewRedisCatalog(mr.Addr())
+	t.Cleanup(func() { c.Close() })
+
+	return c, mr
+}
+
+func TestSeedKeepsExistingItems(t *testing.T) {
+	c, _ := newTestRedisCatalog(t)
+	ctx := context.Background()
+
+	if err := c.Seed(ctx, []Item{hat}); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := hat
+	edited.Price = 999
+	socks := Item{SKU: "SOCKS", Name: "socks", Colors: []string{"red"}, MaxOrderQuantity: 10, Price: 499, Currency: "EUR"}
+	if err := c.Seed(ctx, []Item{edited, socks}); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := c.Items(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Name != "hat" || items[1].Name != "socks" {
+		t.Fatalf("items = %v, want hat and socks", items)
+	}
+	if items[0].Price != hat.Price {
+		t.Errorf("hat price = %d after seeding again, want %d", items[0].Price, hat.Price)
+	}
+
+	if _, err := c.Item(ctx, "pants"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRedisCatalogRejectsInvalidItems(t *testing.T) {
+	noColors := hat
+	noColors.Colors = nil
+	negative := hat
+	negative.MaxOrderQuantity = -1
+	renamed := hat
+	renamed.Name = "cap"
+
+	tests := []struct {
+		name string
+		item any
+	}{
+		{"no colors", noColors},
+		{"negative max quantity", negative},
+		{"stored under another name", renamed},
+		{"not an item", "hat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mr := newTestRedisCatalog(t)
+			ctx := context.Background()
+
+			value, err := json.Marshal(tt.item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mr.HSet(itemsKey, "hat", string(value))
+
+			if _, err := c.Item(ctx, "hat"); err == nil {
+				t.Error("Item accepted an invalid item")
+			}
+			if _, err := c.Items(ctx); err == nil {
+				t.Error("Items accepted an invalid item")
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Violation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// ValidationError lists every way a product breaks the catalog rules. It
+// converts to an InvalidArgument status with BadRequest details when
+// returned from a gRPC handler.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Add(field, format string, args ...any) {
+	e.Violations = append(e.Violations, Violation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+func (e *ValidationError) Error() string {
+	violations := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		violations[i] = v.Field + ": " + v.Description
+	}
+
+	return "invalid product: " + strings.Join(violations, "; ")
+}
+
+func (e *ValidationError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error      string      `json:"error"`
+		Violations []Violation `json:"violations"`
+	}{
+		Error:      "invalid product",
+		Violations: e.Violations,
+	})
+}
+
+func (e *ValidationError) GRPCStatus() *status.Status {
+	st := status.New(codes.InvalidArgument, e.Error())
+
+	badRequest := &errdetails.BadRequest{}
+	for _, v := range e.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+
+	detailed, err := st.WithDetails(badRequest)
+	if err != nil {
+		return st
+	}
+
+	return detailed
+}
+
+// Validate checks that p names a catalog item in one of its colors and in a
+// quantity it may be ordered in, and returns that item. Broken rules are
+// reported together as a *ValidationError; any other error means the
+// catalog couldn't be read.
+func Validate(ctx context.Context, c Catalog, p *otelworkshop.Product) (Item, error) {
+	invalid := &ValidationError{}
+
+	var item *Item
+	if p.Name == "" {
+		invalid.Add("name", "is required")
+	} else {
+		found, err := c.Item(ctx, p.Name)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			invalid.Add("name", "unknown product %q", p.Name)
+		case err != nil:
+			return Item{}, err
+		default:
+			item = &found
+		}
+	}
+
+	if p.Color == "" {
+		invalid.Add("color", "is required")
+	} else if item != nil && !slices.Contains(item.Colors, p.Color) {
+		invalid.Add("color", "%s is not available in %q, choose one of %s", p.Name, p.Color, strings.Join(item.Colors, ", "))
+	}
+
+	if p.Quantity < 1 {
+		invalid.Add("quantity", "must be positive")
+	} else if item != nil && p.Quantity > item.MaxOrderQuantity {
+		invalid.Add("quantity", "must not exceed %d for %s", item.MaxOrderQuantity, p.Name)
+	}
+
+	if len(invalid.Violations) > 0 {
+		return Item{}, invalid
+	}
+
+	return *item, nil
+}
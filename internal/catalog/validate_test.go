@@ -0,0 +1,91 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidate(t *testing.T) {
+	c := NewMemoryCatalog(hat)
+
+	tests := []struct {
+		name    string
+		product *otelworkshop.Product
+		fields  []string
+	}{
+		{"valid", &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 5}, nil},
+		{"unknown name", &otelworkshop.Product{Name: "cape", Color: "red", Quantity: 1}, []string{"name"}},
+		{"missing name", &otelworkshop.Product{Color: "red", Quantity: 1}, []string{"name"}},
+		{"unknown color", &otelworkshop.Product{Name: "hat", Color: "green", Quantity: 1}, []string{"color"}},
+		{"zero quantity", &otelworkshop.Product{Name: "hat", Color: "red"}, []string{"quantity"}},
+		{"negative quantity", &otelworkshop.Product{Name: "hat", Color: "red", Quantity: -1}, []string{"quantity"}},
+		{"above max quantity", &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 6}, []string{"quantity"}},
+		{"everything wrong", &otelworkshop.Product{Name: "hat", Color: "green", Quantity: 6}, []string{"color", "quantity"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := Validate(context.Background(), c, tt.product)
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item.Name != hat.Name {
+					t.Errorf("item = %v, want %v", item, hat)
+				}
+				return
+			}
+
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("err = %v, want a *ValidationError", err)
+			}
+			var fields []string
+			for _, v := range invalid.Violations {
+				fields = append(fields, v.Field)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("violated %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidationErrorRendering(t *testing.T) {
+	invalid := &ValidationError{}
+	invalid.Add("color", "%s is not available in %q", "hat", "green")
+	invalid.Add("quantity", "must be positive")
+
+	data, err := json.Marshal(invalid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"error":"invalid product","violations":[{"field":"color","description":"hat is not available in \"green\""},{"field":"quantity","description":"must be positive"}]}`
+	if string(data) != want {
+		t.Errorf("JSON = %s, want %s", data, want)
+	}
+
+	st, ok := status.FromError(invalid)
+	if !ok || st.Code() != codes.InvalidArgument {
+		t.Fatalf("status = %v, want InvalidArgument", st)
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf("got %d details, want 1", len(st.Details()))
+	}
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok {
+		t.Fatalf("detail = %T, want *errdetails.BadRequest", st.Details()[0])
+	}
+	if got := badRequest.FieldViolations; len(got) != 2 || got[0].Field != "color" || got[1].Field != "quantity" {
+		t.Errorf("field violations = %v, want color and quantity", got)
+	}
+}
@@ -0,0 +1,287 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"vinted/otel-workshop/internal/random"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
+)
+
+var meter = otel.Meter("vinted/otel-workshop/internal/chaos")
+
+// Targets faults can be injected into.
+const (
+	TargetHTTP         = "http"
+	TargetGRPC         = "grpc"
+	TargetRedis        = "redis"
+	TargetKafkaProduce = "kafka.produce"
+	TargetKafkaConsume = "kafka.consume"
+)
+
+var Targets = []string{TargetHTTP, TargetGRPC, TargetRedis, TargetKafkaProduce, TargetKafkaConsume}
+
+var (
+	ErrInjected = errors.New("chaos: injected fault")
+	ErrPanic    = errors.New("chaos: recovered panic")
+)
+
+// Fault describes what happens to each operation on a target. Rates are
+// probabilities between 0 and 1. Panics are recovered where they are
+// injected and fail the operation with ErrPanic. DropRate only applies to
+// Kafka targets.
+type Fault struct {
+	Latency   time.Duration `yaml:"latency"`
+	ErrorRate float64       `yaml:"error_rate"`
+	PanicRate float64       `yaml:"panic_rate"`
+	DropRate  float64       `yaml:"drop_rate"`
+}
+
+type faultJSON struct {
+	Latency   string  `json:"latency,omitempty"`
+	ErrorRate float64 `json:"error_rate,omitempty"`
+	PanicRate float64 `json:"panic_rate,omitempty"`
+	DropRate  float64 `json:"drop_rate,omitempty"`
+}
+
+func (f Fault) MarshalJSON() ([]byte, error) {
+	v := faultJSON{
+		ErrorRate: f.ErrorRate,
+		PanicRate: f.PanicRate,
+		DropRate:  f.DropRate,
+	}
+	if f.Latency > 0 {
+		v.Latency = f.Latency.String()
+	}
+
+	return json.Marshal(v)
+}
+
+func (f *Fault) UnmarshalJSON(data []byte) error {
+	var v faultJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*f = Fault{
+		ErrorRate: v.ErrorRate,
+		PanicRate: v.PanicRate,
+		DropRate:  v.DropRate,
+	}
+
+	if v.Latency != "" {
+		latency, err := time.ParseDuration(v.Latency)
+		if err != nil {
+			return fmt.Errorf("latency: %w", err)
+		}
+		f.Latency = latency
+	}
+
+	return nil
+}
+
+func (f Fault) validate() error {
+	for _, rate := range []float64{f.ErrorRate, f.PanicRate, f.DropRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("rate %v is not between 0 and 1", rate)
+		}
+	}
+
+	if f.Latency < 0 {
+		return fmt.Errorf("negative latency %s", f.Latency)
+	}
+
+	return nil
+}
+
+// Injector holds the faults configured per target. A nil *Injector is valid
+// and never injects anything.
+type Injector struct {
+	mux    sync.RWMutex
+	faults map[string]Fault
+	random *random.Source
+	count  metric.Int64Counter
+}
+
+func New(rnd *random.Source) *Injector {
+	count, err := meter.Int64Counter("workshop.chaos.faults",
+		metric.WithDescription("Number of faults injected."),
+		metric.WithUnit("{fault}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return &Injector{
+		faults: make(map[string]Fault),
+		random: rnd,
+		count:  count,
+	}
+}
+
+// Load reads faults keyed by target from a YAML or JSON file.
+func (i *Injector) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var faults map[string]Fault
+	if err := yaml.Unmarshal(data, &faults); err != nil {
+		return fmt.Errorf("parse chaos config %s: %w", path, err)
+	}
+
+	for target, fault := range faults {
+		if err := i.Set(target, fault); err != nil {
+			return fmt.Errorf("chaos config %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func (i *Injector) Set(target string, fault Fault) error {
+	if !slices.Contains(Targets, target) {
+		return fmt.Errorf("unknown target %q", target)
+	}
+
+	if err := fault.validate(); err != nil {
+		return fmt.Errorf("target %s: %w", target, err)
+	}
+
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	i.faults[target] = fault
+
+	return nil
+}
+
+func (i *Injector) Clear(target string) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	delete(i.faults, target)
+}
+
+func (i *Injector) ClearAll() {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	clear(i.faults)
+}
+
+func (i *Injector) Faults() map[string]Fault {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+
+	return maps.Clone(i.faults)
+}
+
+func (i *Injector) fault(target string) (Fault, bool) {
+	if i == nil {
+		return Fault{}, false
+	}
+
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+
+	fault, ok := i.faults[target]
+	return fault, ok
+}
+
+// Inject applies the fault configured for target: it waits for the
+// latency, then may panic or return ErrInjected. Every injected fault is
+// recorded as a "chaos.fault" event on the span in ctx.
+func (i *Injector) Inject(ctx context.Context, target string) error {
+	fault, ok := i.fault(target)
+	if !ok {
+		return nil
+	}
+
+	if fault.Latency > 0 {
+		i.record(ctx, target, "latency", attribute.String("chaos.latency", fault.Latency.String()))
+
+		timer := time.NewTimer(fault.Latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	if fault.PanicRate > 0 && i.random.Float64() < fault.PanicRate {
+		i.record(ctx, target, "panic")
+		panic(fmt.Sprintf("chaos: injected panic in %s", target))
+	}
+
+	if fault.ErrorRate > 0 && i.random.Float64() < fault.ErrorRate {
+		i.record(ctx, target, "error")
+		return fmt.Errorf("%w in %s", ErrInjected, target)
+	}
+
+	return nil
+}
+
+// Try is Inject for targets that have no recovery of their own: an injected
+// panic is recovered and returned as an ErrPanic error.
+func (i *Injector) Try(ctx context.Context, target string) (err error) {
+	defer Recover(ctx, &err)
+
+	return i.Inject(ctx, target)
+}
+
+// Recover must be deferred. It turns a panic into an ErrPanic error in *err
+// and records it on the span in ctx, so that a panic fails one operation
+// instead of crashing the service.
+func Recover(ctx context.Context, err *error) {
+	if r := recover(); r != nil {
+		*err = recovered(ctx, r)
+	}
+}
+
+func recovered(ctx context.Context, r any) error {
+	err := fmt.Errorf("%w: %v", ErrPanic, r)
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err, trace.WithStackTrace(true))
+	span.SetStatus(codes.Error, err.Error())
+
+	return err
+}
+
+// Drop reports whether a message should be silently dropped.
+func (i *Injector) Drop(ctx context.Context, target string) bool {
+	fault, ok := i.fault(target)
+	if !ok || fault.DropRate <= 0 || i.random.Float64() >= fault.DropRate {
+		return false
+	}
+
+	i.record(ctx, target, "drop")
+
+	return true
+}
+
+func (i *Injector) record(ctx context.Context, target, kind string, attrs ...attribute.KeyValue) {
+	attrs = append([]attribute.KeyValue{
+		attribute.String("chaos.target", target),
+		attribute.String("chaos.fault", kind),
+	}, attrs...)
+
+	trace.SpanFromContext(ctx).AddEvent("chaos.fault", trace.WithAttributes(attrs...))
+	i.count.Add(ctx, 1, metric.WithAttributes(attrs[:2]...))
+}
@@ -0,0 +1,225 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"vinted/otel-workshop/internal/config"
+	"vinted/otel-workshop/internal/random"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracer returns a tracer whose ended spans are kept by the recorder.
+func newTracer() (trace.Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	return provider.Tracer("test"), recorder
+}
+
+// faultEvents returns the chaos.fault kinds recorded on span.
+func faultEvents(span sdktrace.ReadOnlySpan) []string {
+	var kinds []string
+	for _, event := range span.Events() {
+		if event.Name != "chaos.fault" {
+			continue
+		}
+		for _, attr := range event.Attributes {
+			if attr.Key == "chaos.fault" {
+				kinds = append(kinds, attr.Value.AsString())
+			}
+		}
+	}
+
+	return kinds
+}
+
+func hasException(span sdktrace.ReadOnlySpan) bool {
+	for _, event := range span.Events() {
+		if event.Name == "exception" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestTryRecoversPanic(t *testing.T) {
+	tracer, recorder := newTracer()
+	injector := New(random.New(1))
+	if err := injector.Set(TargetKafkaConsume, Fault{PanicRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := tracer.Start(context.Background(), "consume")
+	err := injector.Try(ctx, TargetKafkaConsume)
+	span.End()
+
+	if !errors.Is(err, ErrPanic) {
+		t.Fatalf("err = %v, want ErrPanic", err)
+	}
+	ended := recorder.Ended()[0]
+	if kinds := faultEvents(ended); len(kinds) != 1 || kinds[0] != "panic" {
+		t.Errorf("faults = %v, want a panic", kinds)
+	}
+	if !hasException(ended) {
+		t.Error("the recovered panic was not recorded on the span")
+	}
+}
+
+func TestNilInjectorInjectsNothing(t *testing.T) {
+	var injector *Injector
+	if err := injector.Try(context.Background(), TargetRedis); err != nil {
+		t.Errorf("err = %v, want nil", err)
+	}
+	if injector.Drop(context.Background(), TargetKafkaConsume) {
+		t.Error("a nil injector dropped a message")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	yamlPath := filepath.Join(t.TempDir(), "chaos.yaml")
+	if err := os.WriteFile(yamlPath, []byte("redis:\n  latency: 50ms\n  error_rate: 0.5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(t.TempDir(), "chaos.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"kafka.consume": {"drop_rate": 0.25}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	injector := New(random.New(1))
+	for _, path := range []string{yamlPath, jsonPath} {
+		if err := injector.Load(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]Fault{
+		TargetRedis:        {Latency: 50 * time.Millisecond, ErrorRate: 0.5},
+		TargetKafkaConsume: {DropRate: 0.25},
+	}
+	if got := injector.Faults(); !maps.Equal(got, want) {
+		t.Errorf("faults = %v, want %v", got, want)
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	// The services read the path of the config file from CHAOS_CONFIG.
+	t.Setenv("CHAOS_CONFIG", filepath.Join("..", "..", "config", "chaos", "example.yaml"))
+	cfg, err := config.Load[struct {
+		ChaosConfig string `envconfig:"CHAOS_CONFIG"`
+	}]()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	injector := New(random.New(1))
+	if err := injector.Load(cfg.ChaosConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	faults := injector.Faults()
+	if len(faults) != len(Targets) {
+		t.Errorf("loaded faults for %d targets, want %d", len(faults), len(Targets))
+	}
+	if got := faults[TargetHTTP]; got.Latency != 300*time.Millisecond || got.ErrorRate != 0.05 {
+		t.Errorf("http fault = %+v, want 300ms latency and a 0.05 error rate", got)
+	}
+}
+
+func TestSetValidatesFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		fault  Fault
+	}{
+		{"unknown target", "postgres", Fault{ErrorRate: 0.1}},
+		{"error rate above 1", TargetRedis, Fault{ErrorRate: 1.5}},
+		{"negative panic rate", TargetRedis, Fault{PanicRate: -0.1}},
+		{"drop rate above 1", TargetKafkaProduce, Fault{DropRate: 2}},
+		{"negative latency", TargetHTTP, Fault{Latency: -time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			injector := New(random.New(1))
+			if err := injector.Set(tt.target, tt.fault); err == nil {
+				t.Error("Set accepted an invalid fault")
+			}
+			if len(injector.Faults()) != 0 {
+				t.Errorf("faults = %v after a rejected Set, want none", injector.Faults())
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "chaos.yaml")
+	if err := os.WriteFile(path, []byte("grpc:\n  error_rate: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(random.New(1)).Load(path); err == nil {
+		t.Error("Load accepted an error rate of 3")
+	}
+}
+
+// inject runs n operations on target, each in its own span, and returns
+// the chaos.fault kinds recorded on each.
+func inject(t *testing.T, seed uint64, target string, fault Fault, n int) [][]string {
+	t.Helper()
+
+	tracer, recorder := newTracer()
+	injector := New(random.New(seed))
+	if err := injector.Set(target, fault); err != nil {
+		t.Fatal(err)
+	}
+
+	for range n {
+		ctx, span := tracer.Start(context.Background(), "operation")
+		err := injector.Inject(ctx, target)
+		if err != nil && !errors.Is(err, ErrInjected) {
+			t.Fatal(err)
+		}
+		injector.Drop(ctx, target)
+		span.End()
+	}
+
+	kinds := make([][]string, n)
+	for i, span := range recorder.Ended() {
+		kinds[i] = faultEvents(span)
+	}
+
+	return kinds
+}
+
+func TestInjectRecordsFaults(t *testing.T) {
+	fault := Fault{Latency: time.Millisecond, ErrorRate: 0.5, DropRate: 0.5}
+	runs := inject(t, 1, TargetKafkaConsume, fault, 20)
+
+	counts := make(map[string]int)
+	for i, kinds := range runs {
+		if len(kinds) == 0 || kinds[0] != "latency" {
+			t.Errorf("operation %d recorded %v, want latency first", i, kinds)
+		}
+		for _, kind := range kinds {
+			counts[kind]++
+		}
+	}
+	if counts["error"] == 0 || counts["error"] == len(runs) {
+		t.Errorf("%d of %d operations failed, want some", counts["error"], len(runs))
+	}
+	if counts["drop"] == 0 || counts["drop"] == len(runs) {
+		t.Errorf("%d of %d operations dropped, want some", counts["drop"], len(runs))
+	}
+
+	if again := inject(t, 1, TargetKafkaConsume, fault, 20); !slices.EqualFunc(runs, again, slices.Equal) {
+		t.Errorf("the same seed injected %v, then %v", runs, again)
+	}
+}
@@ -0,0 +1,33 @@
+package chaos
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor injects TargetGRPC faults into every unary method
+// except health checks. Injected errors are returned as Unavailable and
+// panics, injected or not, as Internal.
+func (i *Injector) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp, err = nil, status.Error(codes.Internal, recovered(ctx, r).Error())
+			}
+		}()
+
+		if info.FullMethod == healthpb.Health_Check_FullMethodName {
+			return handler(ctx, req)
+		}
+
+		if err := i.Inject(ctx, TargetGRPC); err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+
+		return handler(ctx, req)
+	}
+}
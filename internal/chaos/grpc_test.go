@@ -0,0 +1,59 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+
+	"vinted/otel-workshop/internal/random"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptorRecoversPanic(t *testing.T) {
+	tracer, recorder := newTracer()
+	injector := New(random.New(1))
+	if err := injector.Set(TargetGRPC, Fault{PanicRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := tracer.Start(context.Background(), "shop.ShopService/BuyProduct")
+	_, err := injector.UnaryServerInterceptor()(ctx, nil,
+		&grpc.UnaryServerInfo{FullMethod: "/shop.ShopService/BuyProduct"},
+		func(context.Context, any) (any, error) {
+			t.Error("handler called after an injected panic")
+			return nil, nil
+		},
+	)
+	span.End()
+
+	if status.Code(err) != codes.Internal {
+		t.Errorf("err = %v, want Internal", err)
+	}
+	if !hasException(recorder.Ended()[0]) {
+		t.Error("the recovered panic was not recorded on the span")
+	}
+}
+
+func TestUnaryServerInterceptorSkipsHealthChecks(t *testing.T) {
+	injector := New(random.New(1))
+	if err := injector.Set(TargetGRPC, Fault{ErrorRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := func(context.Context, any) (any, error) {
+		return "ok", nil
+	}
+
+	for method, want := range map[string]codes.Code{
+		healthpb.Health_Check_FullMethodName: codes.OK,
+		"/shop.ShopService/BuyProduct":       codes.Unavailable,
+	} {
+		_, err := injector.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		if status.Code(err) != want {
+			t.Errorf("%s: err = %v, want %s", method, err, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package chaos
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"vinted/otel-workshop/internal/health"
+)
+
+const AdminPath = "/chaos"
+
+// Middleware injects TargetHTTP faults into next. Health and admin
+// endpoints are left alone so that faults can always be turned off. Panics,
+// injected or not, are answered with 500.
+func (i *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if skipHTTP(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				http.Error(w, recovered(r.Context(), rec).Error(), http.StatusInternalServerError)
+			}
+		}()
+
+		if err := i.Inject(r.Context(), TargetHTTP); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func skipHTTP(path string) bool {
+	return path == health.LivenessPath ||
+		path == health.ReadinessPath ||
+		path == AdminPath ||
+		strings.HasPrefix(path, AdminPath+"/")
+}
+
+// Register adds the admin endpoints that toggle faults at runtime:
+//
+//	GET    /chaos           lists the faults per target
+//	PUT    /chaos/{target}  sets the fault for a target from a JSON body
+//	DELETE /chaos/{target}  clears the fault for a target
+//	DELETE /chaos           clears all faults
+func (i *Injector) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+AdminPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, i.Faults())
+	})
+
+	mux.HandleFunc("PUT "+AdminPath+"/{target}", func(w http.ResponseWriter, r *http.Request) {
+		var fault Fault
+		if err := json.NewDecoder(r.Body).Decode(&fault); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := i.Set(r.PathValue("target"), fault); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, i.Faults())
+	})
+
+	mux.HandleFunc("DELETE "+AdminPath+"/{target}", func(w http.ResponseWriter, r *http.Request) {
+		i.Clear(r.PathValue("target"))
+		writeJSON(w, http.StatusOK, i.Faults())
+	})
+
+	mux.HandleFunc("DELETE "+AdminPath, func(w http.ResponseWriter, r *http.Request) {
+		i.ClearAll()
+		writeJSON(w, http.StatusOK, i.Faults())
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,118 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"vinted/otel-workshop/internal/health"
+	"vinted/otel-workshop/internal/random"
+)
+
+func TestMiddlewareRecoversPanic(t *testing.T) {
+	injector := New(random.New(1))
+	if err := injector.Set(TargetHTTP, Fault{PanicRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := injector.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("handler called after an injected panic")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/order", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+}
+
+func TestMiddlewareSkipsHealthAndAdmin(t *testing.T) {
+	injector := New(random.New(1))
+	if err := injector.Set(TargetHTTP, Fault{ErrorRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := injector.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for path, want := range map[string]int{
+		health.LivenessPath:  http.StatusNoContent,
+		health.ReadinessPath: http.StatusNoContent,
+		AdminPath:            http.StatusNoContent,
+		AdminPath + "/redis": http.StatusNoContent,
+		"/order":             http.StatusInternalServerError,
+		"/chaosmonkey":       http.StatusInternalServerError,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("%s: status = %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestAdminTogglesFaults(t *testing.T) {
+	injector := New(random.New(1))
+	mux := http.NewServeMux()
+	injector.Register(mux)
+
+	do := func(method, path, body string) map[string]Fault {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status = %d: %s", method, path, w.Code, w.Body)
+		}
+
+		var faults map[string]Fault
+		if err := json.NewDecoder(w.Body).Decode(&faults); err != nil {
+			t.Fatal(err)
+		}
+
+		return faults
+	}
+
+	faults := do(http.MethodPut, "/chaos/redis", `{"latency": "200ms", "error_rate": 0.2}`)
+	if want := (Fault{Latency: 200 * time.Millisecond, ErrorRate: 0.2}); faults[TargetRedis] != want {
+		t.Errorf("redis fault = %+v, want %+v", faults[TargetRedis], want)
+	}
+	do(http.MethodPut, "/chaos/grpc", `{"error_rate": 1}`)
+
+	// Faults apply as soon as they are set.
+	if err := injector.Inject(context.Background(), TargetGRPC); !errors.Is(err, ErrInjected) {
+		t.Errorf("err = %v, want ErrInjected", err)
+	}
+
+	if faults := do(http.MethodGet, "/chaos", ""); len(faults) != 2 {
+		t.Errorf("faults = %v, want redis and grpc", faults)
+	}
+	if faults := do(http.MethodDelete, "/chaos/grpc", ""); len(faults) != 1 {
+		t.Errorf("faults = %v, want only redis", faults)
+	}
+	if err := injector.Inject(context.Background(), TargetGRPC); err != nil {
+		t.Errorf("err = %v after clearing grpc, want nil", err)
+	}
+	if faults := do(http.MethodDelete, "/chaos", ""); len(faults) != 0 {
+		t.Errorf("faults = %v, want none", faults)
+	}
+
+	for _, body := range []string{`{"error_rate": 2}`, `{"latency": "soon"}`, `{`} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/chaos/redis", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: status = %d, want 400", body, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/chaos/postgres", strings.NewReader(`{"error_rate": 0.1}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PUT unknown target: status = %d, want 400", w.Code)
+	}
+}
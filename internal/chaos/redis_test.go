@@ -0,0 +1,36 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"vinted/otel-workshop/internal/random"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisHookFailsCommandOnPanic(t *testing.T) {
+	injector := New(random.New(1))
+	if err := injector.Set(TargetRedis, Fault{PanicRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer client.Close()
+	client.AddHook(injector.RedisHook())
+
+	ctx := context.Background()
+	if err := client.Set(ctx, "key", "value", 0).Err(); !errors.Is(err, ErrPanic) {
+		t.Errorf("command err = %v, want ErrPanic", err)
+	}
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Get(ctx, "key")
+		return nil
+	})
+	if !errors.Is(err, ErrPanic) {
+		t.Errorf("pipeline err = %v, want ErrPanic", err)
+	}
+}
@@ -0,0 +1,94 @@
+package customer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"vinted/otel-workshop/internal/random"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// BaggageKey is the baggage member that carries the customer ID from the
+// buyer to the shop, and from there onto every span and log record.
+const BaggageKey = "customer.id"
+
+// idLength is the length of the IDs NewPool makes: "c" and 12 hex digits.
+const idLength = 13
+
+var ErrInvalidID = errors.New("invalid customer ID")
+
+var names = []string{
+	"John",
+	"Jane",
+	"Jack",
+	"Jill",
+	"James",
+}
+
+var surnames = []string{
+	"Doe",
+	"Smith",
+	"Johnson",
+	"Brown",
+	"Williams",
+}
+
+type Customer struct {
+	ID      string
+	Name    string
+	Surname string
+}
+
+// NewPool returns size customers with random names and IDs drawn from rnd,
+// so that a seeded source always yields the same customers.
+func NewPool(rnd *random.Source, size int) []*Customer {
+	customers := make([]*Customer, size)
+	for i := range customers {
+		customers[i] = &Customer{
+			ID:      fmt.Sprintf("c%012x", rnd.Int64(1<<48)),
+			Name:    random.Item(rnd, names),
+			Surname: random.Item(rnd, surnames),
+		}
+	}
+
+	return customers
+}
+
+// ValidID reports whether id has the format of the IDs NewPool makes. IDs
+// arrive in baggage from outside, so they must be checked before they are
+// used in e.g. Redis keys.
+func ValidID(id string) bool {
+	if len(id) != idLength || id[0] != 'c' {
+		return false
+	}
+
+	for _, c := range id[1:] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ContextWithID returns ctx with id added to its baggage.
+func ContextWithID(ctx context.Context, id string) (context.Context, error) {
+	member, err := baggage.NewMember(BaggageKey, id)
+	if err != nil {
+		return ctx, err
+	}
+
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, err
+	}
+
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// IDFromContext returns the customer ID in the ctx baggage, if any.
+func IDFromContext(ctx context.Context) string {
+	return baggage.FromContext(ctx).Member(BaggageKey).Value()
+}
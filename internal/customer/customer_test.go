@@ -0,0 +1,29 @@
+package customer
+
+import (
+	"testing"
+
+	"vinted/otel-workshop/internal/random"
+)
+
+func TestValidID(t *testing.T) {
+	for _, c := range NewPool(random.New(1), 100) {
+		if !ValidID(c.ID) {
+			t.Errorf("ValidID(%q) = false for a pool customer", c.ID)
+		}
+	}
+
+	for _, id := range []string{
+		"",
+		"c0123456789abc",
+		"c0123456789",
+		"d0123456789ab",
+		"c0123456789AB",
+		"c01234567:*ab",
+		"c0123456789ab:purchases",
+	} {
+		if ValidID(id) {
+			t.Errorf("ValidID(%q) = true", id)
+		}
+	}
+}
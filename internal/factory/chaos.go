@@ -0,0 +1,43 @@
+package factory
+
+import (
+	"context"
+
+	"vinted/otel-workshop/internal/chaos"
+)
+
+// ChaosShipper injects kafka.produce faults in front of a Shipper. Dropped
+// products are reported as shipped but never reach Kafka.
+type ChaosShipper struct {
+	shipper  Shipper
+	injector *chaos.Injector
+}
+
+func NewChaosShipper(shipper Shipper, injector *chaos.Injector) *ChaosShipper {
+	return &ChaosShipper{
+		shipper:  shipper,
+		injector: injector,
+	}
+}
+
+func (s *ChaosShipper) Ship(ctx context.Context, parcels []Parcel) ShipResult {
+	if err := s.injector.Try(ctx, chaos.TargetKafkaProduce); err != nil {
+		var result ShipResult
+		for _, p := range parcels {
+			result.Failures = append(result.Failures, ShipFailure{Parcel: p, Err: err})
+		}
+		return result
+	}
+
+	var kept []Parcel
+	for _, p := range parcels {
+		if !s.injector.Drop(ctx, chaos.TargetKafkaProduce) {
+			kept = append(kept, p)
+		}
+	}
+
+	result := s.shipper.Ship(ctx, kept)
+	result.Shipped += len(parcels) - len(kept)
+
+	return result
+}
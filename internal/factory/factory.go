@@ -2,17 +2,27 @@ package factory
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log/slog"
+	"time"
+
+	"vinted/otel-workshop/internal/catalog"
 	"vinted/otel-workshop/internal/product"
 	"vinted/otel-workshop/internal/random"
 	"vinted/otel-workshop/pb/genproto/otelworkshop"
 
 	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	tracer = otel.Tracer("vinted/otel-workshop/internal/factory")
+	meter  = otel.Meter("vinted/otel-workshop/internal/factory")
 )
 
 type Shipper interface {
-	Ship(context.Context, []*otelworkshop.Product) error
+	Ship(context.Context, []Parcel) ShipResult
 }
 
 type Factory interface {
@@ -21,37 +31,68 @@ type Factory interface {
 
 type ProductFactory struct {
 	maxProduction int
+	catalog       catalog.Catalog
 	shipper       Shipper
+	random        *random.Source
 	logger        *slog.Logger
+	produced      metric.Int64Counter
 }
 
-func NewProductFactory(logger *slog.Logger, maxProduction int, shipper Shipper) *ProductFactory {
+func NewProductFactory(logger *slog.Logger, maxProduction int, cat catalog.Catalog, shipper Shipper, rnd *random.Source) *ProductFactory {
+	produced, err := meter.Int64Counter("workshop.products.produced",
+		metric.WithDescription("Number of products produced by the factory."),
+		metric.WithUnit("{product}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
 	return &ProductFactory{
 		maxProduction: maxProduction,
+		catalog:       cat,
 		shipper:       shipper,
+		random:        rnd,
 		logger:        logger,
+		produced:      produced,
 	}
 }
 
 func (f *ProductFactory) Produce(ctx context.Context) error {
+	items, err := f.catalog.Items(ctx)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errors.New("catalog is empty")
+	}
+
 	var products []*otelworkshop.Product
 
-	for i := 0; i < random.Int(f.maxProduction); i++ {
-		products = append(products, product.New())
+	count := f.random.Int(f.maxProduction)
+	for i := 0; i < count; i++ {
+		item := random.Item(f.random, items)
+		p := &otelworkshop.Product{
+			Name:  item.Name,
+			Color: random.Item(f.random, item.Colors),
+		}
+		f.produced.Add(ctx, 1, metric.WithAttributes(product.Attributes(p)...))
+		products = append(products, p)
 	}
 
-	f.logger.Info("produced products", "count", len(products))
+	f.logger.InfoContext(ctx, "produced products", "count", len(products))
 
-	return f.shipper.Ship(ctx, products)
+	return f.shipper.Ship(ctx, NewParcels(products...)).Err()
 }
 
 type KafkaShipper struct {
 	topic    string
+	encoding product.Encoding
 	producer sarama.SyncProducer
 	logger   *slog.Logger
+	metrics  *shipperMetrics
 }
 
-func NewKafkaShipper(logger *slog.Logger, brokerAddresses []string, topic string) (*KafkaShipper, error) {
+func NewKafkaShipper(logger *slog.Logger, brokerAddresses []string, topic string, encoding product.Encoding) (*KafkaShipper, error) {
 	saramaConfig := sarama.NewConfig()
 	saramaConfig.Producer.Return.Successes = true
 
@@ -60,34 +101,72 @@ func NewKafkaShipper(logger *slog.Logger, brokerAddresses []string, topic string
 		return nil, err
 	}
 
+	return NewKafkaShipperWithProducer(logger, producer, topic, encoding), nil
+}
+
+// NewKafkaShipperWithProducer ships through any SyncProducer, e.g. a mock.
+func NewKafkaShipperWithProducer(logger *slog.Logger, producer sarama.SyncProducer, topic string, encoding product.Encoding) *KafkaShipper {
 	return &KafkaShipper{
 		topic:    topic,
+		encoding: encoding,
 		producer: producer,
 		logger:   logger,
-	}, nil
+		metrics:  newShipperMetrics(),
+	}
 }
 
-func (s *KafkaShipper) Ship(ctx context.Context, products []*otelworkshop.Product) error {
-	var messages []*sarama.ProducerMessage
+func (s *KafkaShipper) Ship(ctx context.Context, parcels []Parcel) ShipResult {
+	ctx, span := startShipSpan(ctx, s.topic, len(parcels))
+	defer span.End()
+
+	result := ShipResult{}
+	messages := make([]*sarama.ProducerMessage, 0, len(parcels))
 
-	for _, product := range products {
-		productJson, err := json.Marshal(product)
+	for i, p := range parcels {
+		message, err := newProducerMessage(ctx, s.topic, s.encoding, p)
 		if err != nil {
-			return err
+			result.Failures = append(result.Failures, ShipFailure{Parcel: p, Err: err})
+			continue
 		}
+		message.Metadata = i
 
-		messages = append(messages, &sarama.ProducerMessage{
-			Topic: s.topic,
-			Value: sarama.ByteEncoder(productJson),
-		})
+		messages = append(messages, message)
 	}
 
+	start := time.Now()
 	err := s.producer.SendMessages(messages)
-	if err != nil {
-		return err
+
+	failed := make(map[int]error)
+	var producerErrs sarama.ProducerErrors
+	switch {
+	case errors.As(err, &producerErrs):
+		for _, producerErr := range producerErrs {
+			failed[producerErr.Msg.Metadata.(int)] = producerErr.Err
+		}
+	case err != nil:
+		for _, message := range messages {
+			failed[message.Metadata.(int)] = err
+		}
 	}
 
-	s.logger.Info("shipped products", "count", len(products))
+	for _, message := range messages {
+		p := parcels[message.Metadata.(int)]
+		err := failed[message.Metadata.(int)]
+		s.metrics.record(ctx, s.topic, p.Product, time.Since(start), err)
+		if err != nil {
+			result.Failures = append(result.Failures, ShipFailure{Parcel: p, Err: err})
+			continue
+		}
+		result.Shipped++
+	}
+
+	endShipSpan(span, result)
+
+	s.logger.InfoContext(ctx, "shipped products", "count", result.Shipped, "failed", len(result.Failures))
+
+	return result
+}
 
-	return nil
+func (s *KafkaShipper) Close() error {
+	return s.producer.Close()
 }
@@ -0,0 +1,52 @@
+package factory
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/random"
+)
+
+type recordingShipper struct {
+	shipped []string
+}
+
+func (s *recordingShipper) Ship(_ context.Context, parcels []Parcel) ShipResult {
+	for _, p := range parcels {
+		s.shipped = append(s.shipped, p.Product.Color+" "+p.Product.Name)
+	}
+
+	return ShipResult{Shipped: len(parcels)}
+}
+
+func produce(t *testing.T, seed uint64) []string {
+	t.Helper()
+
+	shipper := &recordingShipper{}
+	factory := NewProductFactory(slog.New(slog.NewTextHandler(io.Discard, nil)), 20, catalog.NewMemoryCatalog(catalog.Default()...), shipper, random.New(seed))
+	for range 10 {
+		if err := factory.Produce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return shipper.shipped
+}
+
+func TestProduceIsDeterministicPerSeed(t *testing.T) {
+	a, b := produce(t, 42), produce(t, 42)
+	if len(a) == 0 {
+		t.Fatal("nothing produced")
+	}
+	if !slices.Equal(a, b) {
+		t.Errorf("same seed produced differently:\n%v\n%v", a, b)
+	}
+
+	if c := produce(t, 43); slices.Equal(a, c) {
+		t.Error("different seeds produced the same")
+	}
+}
@@ -0,0 +1,356 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"vinted/otel-workshop/internal/kafka"
+	"vinted/otel-workshop/internal/order"
+	"vinted/otel-workshop/internal/product"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OrderQueue persists accepted orders until a worker manufactures them.
+type OrderQueue interface {
+	Enqueue(ctx context.Context, order *otelworkshop.Product) error
+}
+
+// KafkaOrderQueue keeps orders in a Kafka topic, keyed by order ID, and
+// hands them to an OrderWorker through a consumer group. Orders are
+// manufactured at least once: an order interrupted by a restart is
+// manufactured again from the start.
+type KafkaOrderQueue struct {
+	topic         string
+	encoding      product.Encoding
+	producer      sarama.SyncProducer
+	consumerGroup sarama.ConsumerGroup
+	groupID       string
+	logger        *slog.Logger
+}
+
+func NewKafkaOrderQueue(logger *slog.Logger, brokerAddresses []string, topic, groupID string, encoding product.Encoding) (*KafkaOrderQueue, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+
+	producer, err := sarama.NewSyncProducer(brokerAddresses, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	consumerGroup, err := sarama.NewConsumerGroup(brokerAddresses, groupID, saramaConfig)
+	if err != nil {
+		return nil, errors.Join(err, producer.Close())
+	}
+
+	return &KafkaOrderQueue{
+		topic:         topic,
+		encoding:      encoding,
+		producer:      producer,
+		consumerGroup: consumerGroup,
+		groupID:       groupID,
+		logger:        logger,
+	}, nil
+}
+
+func (q *KafkaOrderQueue) Enqueue(ctx context.Context, o *otelworkshop.Product) error {
+	ctx, span := tracer.Start(ctx, q.topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(q.topic),
+			semconv.MessagingKafkaMessageKey(o.OrderId),
+		),
+	)
+	defer span.End()
+
+	message, err := newProducerMessage(ctx, q.topic, q.encoding, Parcel{ID: o.OrderId, Product: o})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	message.Key = sarama.StringEncoder(o.OrderId)
+
+	partition, offset, err := q.producer.SendMessage(message)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.SetAttributes(
+		semconv.MessagingDestinationPartitionID(strconv.Itoa(int(partition))),
+		semconv.MessagingKafkaOffset(int(offset)),
+	)
+
+	return nil
+}
+
+// Work feeds queued orders to worker until ctx is done.
+func (q *KafkaOrderQueue) Work(ctx context.Context, worker *OrderWorker) error {
+	handler := &orderHandler{queue: q, worker: worker}
+
+	for ctx.Err() == nil {
+		err := q.consumerGroup.Consume(ctx, []string{q.topic}, handler)
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return nil
+		}
+		if err != nil {
+			q.logger.ErrorContext(ctx, "failed to consume orders", "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (q *KafkaOrderQueue) Close() error {
+	return errors.Join(q.consumerGroup.Close(), q.producer.Close())
+}
+
+type orderHandler struct {
+	queue  *KafkaOrderQueue
+	worker *OrderWorker
+}
+
+func (h *orderHandler) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *orderHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *orderHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			if err := h.handleMessage(session, message); err != nil {
+				return err
+			}
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (h *orderHandler) handleMessage(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) error {
+	ctx := kafka.Extract(session.Context(), message)
+	ctx, span := tracer.Start(ctx, message.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(message.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(int(message.Partition))),
+			semconv.MessagingKafkaOffset(int(message.Offset)),
+			semconv.MessagingConsumerGroupName(h.queue.groupID),
+			semconv.MessagingKafkaMessageKey(string(message.Key)),
+		),
+	)
+	defer span.End()
+
+	carrier := kafka.NewConsumerMessageCarrier(message)
+	o, err := product.Unmarshal(
+		carrier.Get(kafka.HeaderContentType),
+		carrier.Get(kafka.HeaderSchemaVersion),
+		message.Value,
+	)
+	if err != nil {
+		// Retrying can't fix a malformed order, so it is skipped.
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		h.queue.logger.ErrorContext(ctx, "skipping malformed order", "error", err)
+		session.MarkMessage(message, "")
+		return nil
+	}
+
+	err = h.worker.Manufacture(ctx, o)
+	if err != nil && ctx.Err() != nil {
+		// Left unmarked so that the order is redelivered after a restart.
+		return nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	session.MarkMessage(message, "")
+
+	return nil
+}
+
+// OrderWorker manufactures orders in chunks of bounded size, so that memory
+// use does not depend on the order quantity.
+type OrderWorker struct {
+	shipper   Shipper
+	orders    order.Store
+	chunkSize int
+	retry     int
+	backoff   time.Duration
+	logger    *slog.Logger
+	active    metric.Int64UpDownCounter
+	completed metric.Int64Counter
+	progress  metric.Int64Counter
+}
+
+// NewOrderWorker returns a worker that ships orders through shipper.
+// Orders are advanced in orders unless it is nil.
+func NewOrderWorker(logger *slog.Logger, shipper Shipper, orders order.Store, chunkSize int) *OrderWorker {
+	w := &OrderWorker{
+		shipper:   shipper,
+		orders:    orders,
+		chunkSize: chunkSize,
+		retry:     3,
+		backoff:   time.Second,
+		logger:    logger,
+	}
+
+	var err error
+	w.active, err = meter.Int64UpDownCounter("workshop.orders.active",
+		metric.WithDescription("Number of orders being manufactured."),
+		metric.WithUnit("{order}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	w.completed, err = meter.Int64Counter("workshop.orders.completed",
+		metric.WithDescription("Number of orders the factory finished manufacturing, by outcome."),
+		metric.WithUnit("{order}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	w.progress, err = meter.Int64Counter("workshop.orders.manufactured",
+		metric.WithDescription("Number of ordered products manufactured and shipped."),
+		metric.WithUnit("{product}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return w
+}
+
+// Manufacture ships o.Quantity products, one chunk at a time. A chunk that
+// still fails after retries fails the whole order.
+func (w *OrderWorker) Manufacture(ctx context.Context, o *otelworkshop.Product) error {
+	ctx, span := tracer.Start(ctx, "manufacture order", trace.WithAttributes(
+		attribute.String("order.id", o.OrderId),
+		attribute.Int64("order.quantity", o.Quantity),
+		attribute.Int("order.chunk_size", w.chunkSize),
+	))
+	defer span.End()
+
+	attrs := metric.WithAttributes(product.Attributes(o)...)
+
+	w.active.Add(ctx, 1, attrs)
+	defer w.active.Add(ctx, -1, attrs)
+
+	w.logger.InfoContext(ctx, "manufacturing order", "order_id", o.OrderId, "name", o.Name, "color", o.Color, "quantity", o.Quantity)
+
+	for done := int64(0); done < o.Quantity; {
+		size := min(int64(w.chunkSize), o.Quantity-done)
+
+		if err := w.shipChunk(ctx, o, done, size); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			w.logger.ErrorContext(ctx, "failed to manufacture order", "order_id", o.OrderId, "done", done, "error", err)
+
+			if ctx.Err() == nil {
+				w.advance(ctx, o.OrderId, order.StateFailed)
+				w.completed.Add(ctx, 1, metric.WithAttributes(append(product.Attributes(o), attribute.String("order.outcome", "failed"))...))
+			}
+			return err
+		}
+
+		done += size
+		w.progress.Add(ctx, size, attrs)
+		span.AddEvent("chunk shipped", trace.WithAttributes(
+			attribute.Int64("order.chunk.size", size),
+			attribute.Int64("order.done", done),
+		))
+	}
+
+	// An order is only manufactured once every chunk is, so that one that
+	// fails part way through goes straight from accepted to failed.
+	w.advance(ctx, o.OrderId, order.StateManufactured)
+	w.advance(ctx, o.OrderId, order.StateShipped)
+	w.completed.Add(ctx, 1, metric.WithAttributes(append(product.Attributes(o), attribute.String("order.outcome", "shipped"))...))
+	w.logger.InfoContext(ctx, "manufactured order", "order_id", o.OrderId, "quantity", o.Quantity)
+
+	return nil
+}
+
+// shipChunk ships the products from offset to offset+size of the order.
+// Each product gets a message ID made of the order ID and its position in
+// the order, so that an order manufactured again after a restart ships
+// messages that the warehouse recognises as duplicates.
+func (w *OrderWorker) shipChunk(ctx context.Context, o *otelworkshop.Product, offset, size int64) error {
+	parcels := make([]Parcel, size)
+	for i := range parcels {
+		parcels[i] = Parcel{
+			ID: fmt.Sprintf("%s:%d", o.OrderId, offset+int64(i)),
+			Product: &otelworkshop.Product{
+				Name:     o.Name,
+				Color:    o.Color,
+				Quantity: 1,
+				OrderId:  o.OrderId,
+			},
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		result := w.shipper.Ship(ctx, parcels)
+		err := result.Err()
+		if err == nil {
+			return nil
+		}
+		if attempt >= w.retry {
+			return fmt.Errorf("ship chunk: %w", err)
+		}
+
+		// Only the products that failed are shipped again.
+		parcels = parcels[:0]
+		for _, failure := range result.Failures {
+			parcels = append(parcels, failure.Parcel)
+		}
+
+		select {
+		case <-time.After(w.backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (w *OrderWorker) advance(ctx context.Context, id string, state order.State) {
+	if w.orders == nil || id == "" {
+		return
+	}
+
+	// Orders placed directly with the factory are not tracked in the store.
+	err := w.orders.Advance(ctx, id, state)
+	if err != nil && !errors.Is(err, order.ErrNotFound) {
+		w.logger.WarnContext(ctx, "failed to advance order", "order_id", id, "state", state, "error", err)
+	}
+}
@@ -0,0 +1,112 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"vinted/otel-workshop/internal/order"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+)
+
+// flakyShipper fails the first parcel of every call once.
+type flakyShipper struct {
+	failed map[string]bool
+	ids    []string
+}
+
+func (s *flakyShipper) Ship(_ context.Context, parcels []Parcel) ShipResult {
+	var result ShipResult
+	for i, p := range parcels {
+		if i == 0 && !s.failed[p.ID] {
+			s.failed[p.ID] = true
+			result.Failures = append(result.Failures, ShipFailure{Parcel: p, Err: errors.New("broker unavailable")})
+			continue
+		}
+		s.ids = append(s.ids, p.ID)
+		result.Shipped++
+	}
+
+	return result
+}
+
+func TestManufactureShipsStableMessageIDs(t *testing.T) {
+	manufacture := func() []string {
+		shipper := &flakyShipper{failed: make(map[string]bool)}
+		worker := NewOrderWorker(slog.New(slog.NewTextHandler(io.Discard, nil)), shipper, nil, 2)
+		worker.backoff = 0
+
+		o := &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 5, OrderId: "o1"}
+		if err := worker.Manufacture(context.Background(), o); err != nil {
+			t.Fatal(err)
+		}
+
+		slices.Sort(shipper.ids)
+		return shipper.ids
+	}
+
+	want := []string{"o1:0", "o1:1", "o1:2", "o1:3", "o1:4"}
+
+	// Retried parcels keep their ID, and so does an order manufactured again.
+	for run := 1; run <= 2; run++ {
+		if got := manufacture(); !slices.Equal(got, want) {
+			t.Errorf("run %d shipped %v, want %v", run, got, want)
+		}
+	}
+}
+
+// failingShipper fails every parcel.
+type failingShipper struct{}
+
+func (failingShipper) Ship(_ context.Context, parcels []Parcel) ShipResult {
+	var result ShipResult
+	for _, p := range parcels {
+		result.Failures = append(result.Failures, ShipFailure{Parcel: p, Err: errors.New("broker unavailable")})
+	}
+
+	return result
+}
+
+// recordingStore records the states orders are advanced to.
+type recordingStore struct {
+	order.Store
+	states []order.State
+}
+
+func (s *recordingStore) Advance(ctx context.Context, id string, state order.State) error {
+	s.states = append(s.states, state)
+	return s.Store.Advance(ctx, id, state)
+}
+
+func TestManufactureAdvancesOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		shipper Shipper
+		want    []order.State
+	}{
+		{"shipped", &flakyShipper{failed: make(map[string]bool)}, []order.State{order.StateManufactured, order.StateShipped}},
+		{"failed on the first chunk", failingShipper{}, []order.State{order.StateFailed}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := &recordingStore{Store: order.NewMemoryStore()}
+			o := order.New(&otelworkshop.Product{Name: "hat", Color: "red", Quantity: 4})
+			if err := store.Create(ctx, o); err != nil {
+				t.Fatal(err)
+			}
+
+			worker := NewOrderWorker(slog.New(slog.NewTextHandler(io.Discard, nil)), tt.shipper, store, 2)
+			worker.backoff = 0
+			worker.Manufacture(ctx, &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 4, OrderId: o.ID})
+
+			if !slices.Equal(store.states, tt.want) {
+				t.Errorf("states = %v, want %v", store.states, tt.want)
+			}
+		})
+	}
+}
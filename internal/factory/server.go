@@ -3,32 +3,53 @@ package factory
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/chaos"
+	"vinted/otel-workshop/internal/health"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/internal/telemetry"
 	"vinted/otel-workshop/pb/genproto/otelworkshop"
 )
 
 type FactoryServer struct {
-	logger         *slog.Logger
-	shipper        Shipper
-	factoryAddress string
+	logger      *slog.Logger
+	queue       OrderQueue
+	catalog     catalog.Catalog
+	maxQuantity int64
+	server      *http.Server
 }
 
-func NewFactoryServer(logger *slog.Logger, factoryAddress string, shipper Shipper) *FactoryServer {
-	return &FactoryServer{
-		logger:         logger,
-		shipper:        shipper,
-		factoryAddress: factoryAddress,
+// NewFactoryServer returns a server that accepts catalog orders of up to
+// maxQuantity products into queue.
+func NewFactoryServer(logger *slog.Logger, factoryAddress string, queue OrderQueue, cat catalog.Catalog, maxQuantity int64, checker *health.Checker, injector *chaos.Injector) *FactoryServer {
+	s := &FactoryServer{
+		logger:      logger,
+		queue:       queue,
+		catalog:     cat,
+		maxQuantity: maxQuantity,
 	}
-}
 
-func (s *FactoryServer) StartAndRun() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/make", s.handleMake)
+	checker.Register(mux)
+	injector.Register(mux)
 
-	err := http.ListenAndServe(s.factoryAddress, mux)
-	if err != nil {
+	s.server = &http.Server{
+		Addr:    factoryAddress,
+		Handler: telemetry.HTTPHandler(mux, injector.Middleware),
+	}
+
+	return s
+}
+
+// StartAndRun serves until Shutdown is called.
+func (s *FactoryServer) StartAndRun() error {
+	err := s.server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		s.logger.Error("failed to serve HTTP", "error", err)
 		return err
 	}
@@ -36,6 +57,12 @@ func (s *FactoryServer) StartAndRun() error {
 	return nil
 }
 
+// Shutdown stops accepting connections and waits for in-flight orders to
+// be enqueued.
+func (s *FactoryServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
 func (s *FactoryServer) handleMake(w http.ResponseWriter, r *http.Request) {
 	var p otelworkshop.Product
 
@@ -45,24 +72,43 @@ func (s *FactoryServer) handleMake(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.logger.Info("received order to make", "name", p.Name, "color", p.Color, "quantity", p.Quantity)
+	_, err = catalog.Validate(r.Context(), s.catalog, &p)
+	if err == nil && p.Quantity > s.maxQuantity {
+		invalid := &catalog.ValidationError{}
+		invalid.Add("quantity", "must not exceed %d", s.maxQuantity)
+		err = invalid
+	}
 
-	var products []*otelworkshop.Product
+	var invalid *catalog.ValidationError
+	if errors.As(err, &invalid) {
+		s.logger.WarnContext(r.Context(), "rejected invalid order", "error", invalid)
+		writeJSON(w, http.StatusBadRequest, invalid)
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to validate order", "error", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 
-	for i := 0; i < int(p.Quantity); i++ {
-		products = append(products, &otelworkshop.Product{
-			Name:     p.Name,
-			Color:    p.Color,
-			Quantity: 1,
-		})
+	if p.OrderId == "" {
+		p.OrderId = random.NewID()
 	}
 
-	err = s.shipper.Ship(context.Background(), products)
+	s.logger.InfoContext(r.Context(), "received order to make", "order_id", p.OrderId, "name", p.Name, "color", p.Color, "quantity", p.Quantity)
+
+	err = s.queue.Enqueue(r.Context(), &p)
 	if err != nil {
-		s.logger.Error("failed to ship product", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.ErrorContext(r.Context(), "failed to enqueue order", "order_id", p.OrderId, "error", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeJSON(w, http.StatusAccepted, map[string]string{"order_id": p.OrderId})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
 }
@@ -0,0 +1,344 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"vinted/otel-workshop/internal/kafka"
+	"vinted/otel-workshop/internal/product"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Parcel is a product to ship along with the ID of its message. A parcel
+// that is shipped again keeps its ID, so that the warehouse can tell the
+// copies apart from new products.
+type Parcel struct {
+	ID      string
+	Product *otelworkshop.Product
+}
+
+// NewParcels wraps products in parcels with random IDs.
+func NewParcels(products ...*otelworkshop.Product) []Parcel {
+	parcels := make([]Parcel, len(products))
+	for i, p := range products {
+		parcels[i] = Parcel{ID: random.NewID(), Product: p}
+	}
+
+	return parcels
+}
+
+type ShipFailure struct {
+	Parcel Parcel
+	Err    error
+}
+
+type ShipResult struct {
+	Shipped  int
+	Failures []ShipFailure
+}
+
+// Err summarizes the failures, or returns nil if every product was shipped.
+func (r ShipResult) Err() error {
+	if len(r.Failures) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("failed to ship %d of %d products: %w", len(r.Failures), r.Shipped+len(r.Failures), r.Failures[0].Err)
+}
+
+type AsyncShipperConfig struct {
+	BatchSize   int
+	Linger      time.Duration
+	Compression string
+	MaxInFlight int
+}
+
+// AsyncKafkaShipper ships through a sarama.AsyncProducer, so that messages
+// from concurrent Ship calls are batched together.
+type AsyncKafkaShipper struct {
+	topic    string
+	encoding product.Encoding
+	producer sarama.AsyncProducer
+	logger   *slog.Logger
+	metrics  *shipperMetrics
+	done     chan struct{}
+}
+
+func NewAsyncKafkaShipper(logger *slog.Logger, brokerAddresses []string, topic string, encoding product.Encoding, cfg AsyncShipperConfig) (*AsyncKafkaShipper, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Return.Errors = true
+	saramaConfig.Producer.Flush.Messages = cfg.BatchSize
+	saramaConfig.Producer.Flush.Frequency = cfg.Linger
+	saramaConfig.Net.MaxOpenRequests = cfg.MaxInFlight
+
+	if cfg.Compression != "" {
+		if err := saramaConfig.Producer.Compression.UnmarshalText([]byte(cfg.Compression)); err != nil {
+			return nil, err
+		}
+	}
+
+	producer, err := sarama.NewAsyncProducer(brokerAddresses, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &AsyncKafkaShipper{
+		topic:    topic,
+		encoding: encoding,
+		producer: producer,
+		logger:   logger,
+		metrics:  newShipperMetrics(),
+		done:     make(chan struct{}),
+	}
+	go s.dispatch()
+
+	return s, nil
+}
+
+type shipment struct {
+	wg      sync.WaitGroup
+	mux     sync.Mutex
+	result  ShipResult
+	parcels []Parcel
+}
+
+type delivery struct {
+	ctx      context.Context
+	shipment *shipment
+	index    int
+	start    time.Time
+}
+
+func (s *AsyncKafkaShipper) Ship(ctx context.Context, parcels []Parcel) ShipResult {
+	ctx, span := startShipSpan(ctx, s.topic, len(parcels))
+	defer span.End()
+
+	sh := &shipment{parcels: parcels}
+
+	for i, p := range parcels {
+		message, err := newProducerMessage(ctx, s.topic, s.encoding, p)
+		if err != nil {
+			sh.fail(p, err)
+			continue
+		}
+		message.Metadata = &delivery{ctx: ctx, shipment: sh, index: i, start: time.Now()}
+
+		sh.wg.Add(1)
+		select {
+		case s.producer.Input() <- message:
+		case <-ctx.Done():
+			sh.wg.Done()
+			sh.fail(p, ctx.Err())
+		}
+	}
+
+	sh.wg.Wait()
+
+	endShipSpan(span, sh.result)
+
+	s.logger.InfoContext(ctx, "shipped products", "count", sh.result.Shipped, "failed", len(sh.result.Failures))
+
+	return sh.result
+}
+
+func (s *AsyncKafkaShipper) dispatch() {
+	defer close(s.done)
+
+	successes, errs := s.producer.Successes(), s.producer.Errors()
+	for successes != nil || errs != nil {
+		select {
+		case message, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			s.deliver(message, nil)
+		case producerErr, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			s.deliver(producerErr.Msg, producerErr.Err)
+		}
+	}
+}
+
+func (s *AsyncKafkaShipper) deliver(message *sarama.ProducerMessage, err error) {
+	d, ok := message.Metadata.(*delivery)
+	if !ok {
+		return
+	}
+	defer d.shipment.wg.Done()
+
+	p := d.shipment.parcels[d.index]
+	s.metrics.record(d.ctx, s.topic, p.Product, time.Since(d.start), err)
+
+	if err != nil {
+		d.shipment.fail(p, err)
+		return
+	}
+
+	d.shipment.mux.Lock()
+	d.shipment.result.Shipped++
+	d.shipment.mux.Unlock()
+}
+
+func (sh *shipment) fail(p Parcel, err error) {
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	sh.result.Failures = append(sh.result.Failures, ShipFailure{Parcel: p, Err: err})
+}
+
+// Close flushes buffered messages and waits for their delivery reports.
+func (s *AsyncKafkaShipper) Close() error {
+	err := s.producer.Close()
+	<-s.done
+
+	return err
+}
+
+func newProducerMessage(ctx context.Context, topic string, encoding product.Encoding, p Parcel) (*sarama.ProducerMessage, error) {
+	value, err := product.Marshal(encoding, p.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(kafka.HeaderContentType), Value: []byte(encoding.ContentType())},
+			{Key: []byte(kafka.HeaderSchemaVersion), Value: []byte(product.SchemaVersion)},
+			{Key: []byte(kafka.HeaderMessageID), Value: []byte(p.ID)},
+		},
+	}
+	kafka.Inject(ctx, message)
+
+	return message, nil
+}
+
+func startShipSpan(ctx context.Context, topic string, count int) (context.Context, trace.Span) {
+	return tracer.Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingBatchMessageCount(count),
+		),
+	)
+}
+
+func endShipSpan(span trace.Span, result ShipResult) {
+	span.SetAttributes(attribute.Int("workshop.shipment.failed", len(result.Failures)))
+
+	if err := result.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+type shipperMetrics struct {
+	shipped  metric.Int64Counter
+	sent     metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newShipperMetrics() *shipperMetrics {
+	m := &shipperMetrics{}
+
+	var err error
+	m.shipped, err = meter.Int64Counter("workshop.products.shipped",
+		metric.WithDescription("Number of products shipped to Kafka."),
+		metric.WithUnit("{product}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	m.sent, err = meter.Int64Counter("messaging.client.sent.messages",
+		metric.WithDescription("Number of messages producer attempted to send to the broker."),
+		metric.WithUnit("{message}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	m.duration, err = meter.Float64Histogram("messaging.client.operation.duration",
+		metric.WithDescription("Duration of messaging operation initiated by a producer or consumer client."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return m
+}
+
+// errorTypes names the producer errors worth telling apart on dashboards.
+// Anything else is reported as _OTHER to keep error.type low-cardinality.
+var errorTypes = []struct {
+	err  error
+	name string
+}{
+	{context.Canceled, "canceled"},
+	{context.DeadlineExceeded, "timeout"},
+	{sarama.ErrOutOfBrokers, "out_of_brokers"},
+	{sarama.ErrNotConnected, "not_connected"},
+	{sarama.ErrClosedClient, "closed_client"},
+	{sarama.ErrShuttingDown, "shutting_down"},
+	{sarama.ErrRequestTimedOut, "request_timed_out"},
+	{sarama.ErrMessageSizeTooLarge, "message_too_large"},
+	{sarama.ErrNotLeaderForPartition, "not_leader_for_partition"},
+	{sarama.ErrLeaderNotAvailable, "leader_not_available"},
+	{sarama.ErrUnknownTopicOrPartition, "unknown_topic_or_partition"},
+	{sarama.ErrNotEnoughReplicas, "not_enough_replicas"},
+	{sarama.ErrNotEnoughReplicasAfterAppend, "not_enough_replicas"},
+	{sarama.ErrTopicAuthorizationFailed, "topic_authorization_failed"},
+}
+
+func errorType(err error) attribute.KeyValue {
+	for _, t := range errorTypes {
+		if errors.Is(err, t.err) {
+			return semconv.ErrorTypeKey.String(t.name)
+		}
+	}
+
+	return semconv.ErrorTypeOther
+}
+
+// record reports the outcome of a single message, from the moment it was
+// handed to the producer until the broker acknowledged or rejected it.
+func (m *shipperMetrics) record(ctx context.Context, topic string, p *otelworkshop.Product, latency time.Duration, err error) {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemKafka,
+		semconv.MessagingOperationName("send"),
+		semconv.MessagingDestinationName(topic),
+	}
+	if err != nil {
+		attrs = append(attrs, errorType(err))
+	}
+
+	m.sent.Add(ctx, 1, metric.WithAttributes(attrs...))
+	m.duration.Record(ctx, latency.Seconds(), metric.WithAttributes(attrs...))
+
+	if err == nil {
+		m.shipped.Add(ctx, 1, metric.WithAttributes(product.Attributes(p)...))
+	}
+}
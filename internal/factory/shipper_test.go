@@ -0,0 +1,32 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want attribute.KeyValue
+	}{
+		{sarama.ErrOutOfBrokers, semconv.ErrorTypeKey.String("out_of_brokers")},
+		{sarama.ErrMessageSizeTooLarge, semconv.ErrorTypeKey.String("message_too_large")},
+		{fmt.Errorf("ship: %w", context.DeadlineExceeded), semconv.ErrorTypeKey.String("timeout")},
+		{&sarama.ProducerError{Err: sarama.ErrNotLeaderForPartition}, semconv.ErrorTypeKey.String("not_leader_for_partition")},
+		{sarama.ErrInvalidMessage, semconv.ErrorTypeOther},
+		{errors.New("unexpected"), semconv.ErrorTypeOther},
+	}
+
+	for _, tt := range tests {
+		if got := errorType(tt.err); got != tt.want {
+			t.Errorf("errorType(%v) = %v, want %v", tt.err, got.Value.Emit(), tt.want.Value.Emit())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Serve keeps the overall status of server in sync with the checker by
+// running the checks every interval until ctx is done.
+func (c *Checker) Serve(ctx context.Context, server *health.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if c.Check(ctx).Status != StatusUp {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		server.SetServingStatus("", status)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CheckGRPC asks a grpc.health.v1 server over conn for its overall status.
+func CheckGRPC(ctx context.Context, conn grpc.ClientConnInterface) error {
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// ProbeGRPC dials address and checks it, for use as a container health
+// check where no dedicated probe binary is available.
+func ProbeGRPC(ctx context.Context, address string) error {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return CheckGRPC(ctx, conn)
+}
@@ -0,0 +1,168 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otel.Meter("vinted/otel-workshop/internal/health")
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// CheckFunc reports whether a dependency is usable. It should honour ctx,
+// but checks that do not are still abandoned once the checker times out.
+type CheckFunc func(ctx context.Context) error
+
+type CheckResult struct {
+	Status   Status `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs readiness checks against the real dependencies of a
+// service and records each result in the workshop.health.check.status
+// gauge.
+type Checker struct {
+	mux     sync.Mutex
+	checks  []check
+	timeout time.Duration
+	status  metric.Int64Gauge
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	status, err := meter.Int64Gauge("workshop.health.check.status",
+		metric.WithDescription("Result of the last readiness check: 1 if the dependency is up, 0 otherwise."),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return &Checker{
+		timeout: timeout,
+		status:  status,
+	}
+}
+
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Check runs all checks concurrently. The report is up only if every check
+// succeeded within the timeout.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mux.Lock()
+	checks := c.checks
+	c.mux.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check.fn)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+	for i, check := range checks {
+		result := results[i]
+		report.Checks[check.name] = result
+
+		value := int64(1)
+		if result.Status != StatusUp {
+			value = 0
+			report.Status = StatusDown
+		}
+		c.status.Record(ctx, value, metric.WithAttributes(attribute.String("health.check.name", check.name)))
+	}
+
+	return report
+}
+
+func run(ctx context.Context, fn CheckFunc) CheckResult {
+	start := time.Now()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:   StatusUp,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// Register adds GET /healthz, which succeeds while the process is able to
+// serve HTTP, and GET /readyz, which runs the checks and answers 503 if any
+// of them fails.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+LivenessPath, func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, Report{Status: StatusUp})
+	})
+	mux.HandleFunc("GET "+ReadinessPath, func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Check(r.Context()))
+	})
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != StatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(report)
+}
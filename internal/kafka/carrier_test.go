@@ -0,0 +1,100 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtractThroughProducer(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	member, err := baggage.NewMember("customer.id", "c0123456789ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bag, err := baggage.New(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+	ctx, producerSpan := tracer.Start(ctx, "items publish", trace.WithSpanKind(trace.SpanKindProducer))
+
+	var sent *sarama.ProducerMessage
+	producer := mocks.NewSyncProducer(t, mocks.NewTestConfig())
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	message := &sarama.ProducerMessage{Topic: "items", Value: sarama.StringEncoder("{}")}
+	Inject(ctx, message)
+	if _, _, err := producer.SendMessage(message); err != nil {
+		t.Fatal(err)
+	}
+	producerSpan.End()
+	if err := producer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// What a consumer receives: the same headers on a ConsumerMessage.
+	received := &sarama.ConsumerMessage{Topic: sent.Topic}
+	for _, h := range sent.Headers {
+		received.Headers = append(received.Headers, &sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+
+	consumerCtx := Extract(context.Background(), received)
+
+	if got := baggage.FromContext(consumerCtx).Member("customer.id").Value(); got != "c0123456789ab" {
+		t.Errorf("baggage customer.id = %q, want %q", got, "c0123456789ab")
+	}
+
+	_, consumerSpan := tracer.Start(consumerCtx, "items process", trace.WithSpanKind(trace.SpanKindConsumer))
+	consumerSpan.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+
+	producerSC, consumer := spans[0].SpanContext(), spans[1]
+	if consumer.SpanContext().TraceID() != producerSC.TraceID() {
+		t.Errorf("consumer trace ID = %s, want %s", consumer.SpanContext().TraceID(), producerSC.TraceID())
+	}
+	if consumer.Parent().SpanID() != producerSC.SpanID() {
+		t.Errorf("consumer parent span ID = %s, want producer span %s", consumer.Parent().SpanID(), producerSC.SpanID())
+	}
+	if !consumer.Parent().IsRemote() {
+		t.Error("consumer parent is not remote")
+	}
+}
+
+func TestConsumerMessageCarrierSetReplaces(t *testing.T) {
+	msg := &sarama.ConsumerMessage{}
+	carrier := NewConsumerMessageCarrier(msg)
+
+	carrier.Set("traceparent", "a")
+	carrier.Set("traceparent", "b")
+
+	if got := carrier.Get("traceparent"); got != "b" {
+		t.Errorf("Get = %q, want %q", got, "b")
+	}
+	if keys := carrier.Keys(); len(keys) != 1 {
+		t.Errorf("Keys = %v, want a single key", keys)
+	}
+}
@@ -0,0 +1,36 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+)
+
+// MetadataChecker checks that the brokers are reachable and that they have
+// metadata for the given topics.
+type MetadataChecker struct {
+	client sarama.Client
+	topics []string
+}
+
+func NewMetadataChecker(brokerAddresses []string, topics ...string) (*MetadataChecker, error) {
+	client, err := sarama.NewClient(brokerAddresses, sarama.NewConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetadataChecker{
+		client: client,
+		topics: topics,
+	}, nil
+}
+
+// Check refreshes the topic metadata. sarama does not take a context, so
+// the call is bounded by its own network timeouts rather than by ctx.
+func (c *MetadataChecker) Check(context.Context) error {
+	return c.client.RefreshMetadata(c.topics...)
+}
+
+func (c *MetadataChecker) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,92 @@
+package order
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type MemoryStore struct {
+	mux    sync.RWMutex
+	orders map[string]*Order
+	ids    []string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders: make(map[string]*Order),
+	}
+}
+
+func (s *MemoryStore) Create(_ context.Context, order *Order) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	o := *order
+	s.orders[o.ID] = &o
+	s.ids = append(s.ids, o.ID)
+
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (*Order, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	o := *order
+	return &o, nil
+}
+
+func (s *MemoryStore) List(_ context.Context) ([]*Order, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	orders := make([]*Order, 0, len(s.ids))
+	for i := len(s.ids) - 1; i >= 0; i-- {
+		o := *s.orders[s.ids[i]]
+		orders = append(orders, &o)
+	}
+
+	return orders, nil
+}
+
+func (s *MemoryStore) Advance(_ context.Context, id string, state State) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if slices.Contains(state.before(), order.State) {
+		order.State = state
+		order.UpdatedAt = time.Now().UTC()
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) AddStored(_ context.Context, id string, count int64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	order.Stored += count
+	order.UpdatedAt = time.Now().UTC()
+	if order.Stored >= order.Quantity && slices.Contains(StateStored.before(), order.State) {
+		order.State = StateStored
+	}
+
+	return nil
+}
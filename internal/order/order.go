@@ -0,0 +1,85 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+)
+
+type State string
+
+const (
+	StateAccepted     State = "accepted"
+	StateManufactured State = "manufactured"
+	StateShipped      State = "shipped"
+	StateStored       State = "stored"
+	StateFailed       State = "failed"
+)
+
+// states lists the order lifecycle in the order orders advance through it.
+// StateFailed is not part of it: it can be reached from any state but the
+// terminal StateStored.
+var states = []State{
+	StateAccepted,
+	StateManufactured,
+	StateShipped,
+	StateStored,
+}
+
+// before returns the states an order may advance from to reach s. Orders
+// never move backwards, so a late "shipped" can't overwrite "stored", and
+// neither "stored" nor "failed" is ever left.
+func (s State) before() []State {
+	if s == StateFailed {
+		return states[:len(states)-1]
+	}
+
+	for i, state := range states {
+		if state == s {
+			return states[:i]
+		}
+	}
+
+	return nil
+}
+
+var ErrNotFound = errors.New("order not found")
+
+type Order struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	Quantity  int64     `json:"quantity"`
+	Stored    int64     `json:"stored"`
+	State     State     `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func New(product *otelworkshop.Product) *Order {
+	now := time.Now().UTC()
+
+	return &Order{
+		ID:        random.NewID(),
+		Name:      product.Name,
+		Color:     product.Color,
+		Quantity:  product.Quantity,
+		State:     StateAccepted,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+type Store interface {
+	Create(ctx context.Context, order *Order) error
+	Get(ctx context.Context, id string) (*Order, error)
+	List(ctx context.Context) ([]*Order, error)
+	// Advance moves the order to state unless it is already there or further.
+	Advance(ctx context.Context, id string, state State) error
+	// AddStored records count stored items and marks the order as stored once
+	// all of them are in the warehouse.
+	AddStored(ctx context.Context, id string, count int64) error
+}
@@ -0,0 +1,106 @@
+package order
+
+import (
+	"context"
+	"testing"
+
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	redisStore := NewRedisStore(miniredis.RunT(t).Addr())
+	t.Cleanup(func() { redisStore.Close() })
+
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  redisStore,
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []State
+		want  State
+	}{
+		{"forward", []State{StateManufactured, StateShipped}, StateShipped},
+		{"never backwards", []State{StateShipped, StateManufactured}, StateShipped},
+		{"failed while shipping", []State{StateManufactured, StateFailed}, StateFailed},
+		{"failed is terminal", []State{StateFailed, StateShipped}, StateFailed},
+		{"stored is terminal", []State{StateShipped, StateStored, StateFailed}, StateStored},
+	}
+
+	for name, store := range stores(t) {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				order := New(&otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1})
+				if err := store.Create(ctx, order); err != nil {
+					t.Fatal(err)
+				}
+
+				for _, state := range tt.steps {
+					if err := store.Advance(ctx, order.ID, state); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				got, err := store.Get(ctx, order.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.State != tt.want {
+					t.Errorf("state = %s, want %s", got.State, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestAddStored(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			order := New(&otelworkshop.Product{Name: "hat", Color: "red", Quantity: 2})
+			if err := store.Create(ctx, order); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Advance(ctx, order.ID, StateShipped); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range []State{StateShipped, StateStored} {
+				if err := store.AddStored(ctx, order.ID, 1); err != nil {
+					t.Fatal(err)
+				}
+				got, err := store.Get(ctx, order.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.State != want {
+					t.Errorf("state = %s, want %s", got.State, want)
+				}
+			}
+
+			if err := store.Advance(ctx, order.ID, StateFailed); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := store.Get(ctx, order.ID); got.State != StateStored {
+				t.Errorf("state = %s after failing a stored order, want %s", got.State, StateStored)
+			}
+		})
+	}
+}
+
+func TestAdvanceUnknownOrder(t *testing.T) {
+	for name, store := range stores(t) {
+		if err := store.Advance(context.Background(), random.NewID(), StateShipped); err != ErrNotFound {
+			t.Errorf("%s: err = %v, want ErrNotFound", name, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package order
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+const ordersKey = "orders"
+
+func orderKey(id string) string {
+	return "order:" + id
+}
+
+// advance sets the state of KEYS[1] to ARGV[2] if its current state is one
+// of ARGV[3..]. It returns 0 if the order doesn't exist.
+var advance = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+local current = redis.call("HGET", KEYS[1], "state")
+for i = 3, #ARGV do
+	if ARGV[i] == current then
+		redis.call("HSET", KEYS[1], "state", ARGV[2], "updated_at", ARGV[1])
+		break
+	end
+end
+return 1
+`)
+
+// addStored increments the stored count of KEYS[1] by ARGV[2] and sets the
+// state to "stored" once it reaches the ordered quantity, provided the
+// current state is one of ARGV[3..]. It returns 0 if the order doesn't exist.
+var addStored = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+local stored = redis.call("HINCRBY", KEYS[1], "stored", ARGV[2])
+redis.call("HSET", KEYS[1], "updated_at", ARGV[1])
+if stored < tonumber(redis.call("HGET", KEYS[1], "quantity")) then
+	return 1
+end
+local current = redis.call("HGET", KEYS[1], "state")
+for i = 3, #ARGV do
+	if ARGV[i] == current then
+		redis.call("HSET", KEYS[1], "state", "stored")
+		break
+	end
+end
+return 1
+`)
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(redisAddr string) *RedisStore {
+	return &RedisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr: redisAddr,
+		}),
+	}
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *RedisStore) Create(ctx context.Context, order *Order) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, orderKey(order.ID), toHash(order))
+		pipe.ZAdd(ctx, ordersKey, redis.Z{
+			Score:  float64(order.CreatedAt.UnixNano()),
+			Member: order.ID,
+		})
+		return nil
+	})
+
+	return err
+}
+
+func (s *RedisStore) Get(ctx context.Context, id string) (*Order, error) {
+	hash, err := s.client.HGetAll(ctx, orderKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hash) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return fromHash(hash), nil
+}
+
+func (s *RedisStore) List(ctx context.Context) ([]*Order, error) {
+	ids, err := s.client.ZRevRange(ctx, ordersKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, 0, len(ids))
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			cmds = append(cmds, pipe.HGetAll(ctx, orderKey(id)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]*Order, 0, len(cmds))
+	for _, cmd := range cmds {
+		if hash := cmd.Val(); len(hash) > 0 {
+			orders = append(orders, fromHash(hash))
+		}
+	}
+
+	return orders, nil
+}
+
+func (s *RedisStore) Advance(ctx context.Context, id string, state State) error {
+	args := []any{now(), string(state)}
+	for _, before := range state.before() {
+		args = append(args, string(before))
+	}
+
+	found, err := advance.Run(ctx, s.client, []string{orderKey(id)}, args...).Int()
+	if err != nil {
+		return err
+	}
+
+	if found == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *RedisStore) AddStored(ctx context.Context, id string, count int64) error {
+	args := []any{now(), count}
+	for _, before := range StateStored.before() {
+		args = append(args, string(before))
+	}
+
+	found, err := addStored.Run(ctx, s.client, []string{orderKey(id)}, args...).Int()
+	if err != nil {
+		return err
+	}
+
+	if found == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+func toHash(order *Order) map[string]any {
+	return map[string]any{
+		"id":         order.ID,
+		"name":       order.Name,
+		"color":      order.Color,
+		"quantity":   order.Quantity,
+		"stored":     order.Stored,
+		"state":      string(order.State),
+		"created_at": order.CreatedAt.Format(time.RFC3339Nano),
+		"updated_at": order.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+func fromHash(hash map[string]string) *Order {
+	quantity, _ := strconv.ParseInt(hash["quantity"], 10, 64)
+	stored, _ := strconv.ParseInt(hash["stored"], 10, 64)
+	createdAt, _ := time.Parse(time.RFC3339Nano, hash["created_at"])
+	updatedAt, _ := time.Parse(time.RFC3339Nano, hash["updated_at"])
+
+	return &Order{
+		ID:        hash["id"],
+		Name:      hash["name"],
+		Color:     hash["color"],
+		Quantity:  quantity,
+		Stored:    stored,
+		State:     State(hash["state"]),
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}
+}
@@ -0,0 +1,72 @@
+package product
+
+import (
+	"encoding/json"
+	"fmt"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"google.golang.org/protobuf/proto"
+)
+
+type Encoding string
+
+const (
+	EncodingJSON     Encoding = "json"
+	EncodingProtobuf Encoding = "protobuf"
+
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+
+	// SchemaVersion is bumped on incompatible changes to the encoded Product.
+	SchemaVersion = "1"
+)
+
+func ParseEncoding(s string) (Encoding, error) {
+	switch e := Encoding(s); e {
+	case EncodingJSON, EncodingProtobuf:
+		return e, nil
+	default:
+		return "", fmt.Errorf("unknown product encoding %q", s)
+	}
+}
+
+func (e Encoding) ContentType() string {
+	if e == EncodingProtobuf {
+		return ContentTypeProtobuf
+	}
+
+	return ContentTypeJSON
+}
+
+func Marshal(encoding Encoding, product *otelworkshop.Product) ([]byte, error) {
+	if encoding == EncodingProtobuf {
+		return proto.Marshal(product)
+	}
+
+	return json.Marshal(product)
+}
+
+// Unmarshal decodes data according to contentType. Messages without a
+// content type predate the header and are JSON.
+func Unmarshal(contentType, schemaVersion string, data []byte) (*otelworkshop.Product, error) {
+	if schemaVersion != "" && schemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("unsupported product schema version %q", schemaVersion)
+	}
+
+	var product otelworkshop.Product
+
+	switch contentType {
+	case ContentTypeProtobuf:
+		if err := proto.Unmarshal(data, &product); err != nil {
+			return nil, err
+		}
+	case ContentTypeJSON, "":
+		if err := json.Unmarshal(data, &product); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported product content type %q", contentType)
+	}
+
+	return &product, nil
+}
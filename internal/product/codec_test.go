@@ -0,0 +1,69 @@
+package product
+
+import (
+	"testing"
+
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var benchmarkProduct = &otelworkshop.Product{
+	Name:     NameShoes,
+	Color:    ColorBlue,
+	Quantity: 1,
+	OrderId:  "3f2b8c0d9e6a41f7b5c2d8e0a1f4c6b9",
+	Price:    4999,
+	Currency: "EUR",
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, encoding := range []Encoding{EncodingJSON, EncodingProtobuf} {
+		data, err := Marshal(encoding, benchmarkProduct)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+
+		got, err := Unmarshal(encoding.ContentType(), SchemaVersion, data)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if !proto.Equal(got, benchmarkProduct) {
+			t.Errorf("%s: got %v, want %v", encoding, got, benchmarkProduct)
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	for _, encoding := range []Encoding{EncodingJSON, EncodingProtobuf} {
+		b.Run(string(encoding), func(b *testing.B) {
+			var size int
+			for range b.N {
+				data, err := Marshal(encoding, benchmarkProduct)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes/msg")
+		})
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, encoding := range []Encoding{EncodingJSON, EncodingProtobuf} {
+		b.Run(string(encoding), func(b *testing.B) {
+			data, err := Marshal(encoding, benchmarkProduct)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for range b.N {
+				if _, err := Unmarshal(encoding.ContentType(), SchemaVersion, data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,9 @@
 package product
 
 import (
-	"vinted/otel-workshop/internal/random"
 	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 //go:generate go install google.golang.org/protobuf/cmd/protoc-gen-go
@@ -39,13 +40,6 @@ var colors = []string{
 	ColorBlack,
 }
 
-func New() *otelworkshop.Product {
-	return &otelworkshop.Product{
-		Name:  random.Item(names),
-		Color: random.Item(colors),
-	}
-}
-
 func Names() []string {
 	return names
 }
@@ -53,3 +47,10 @@ func Names() []string {
 func Colors() []string {
 	return colors
 }
+
+func Attributes(product *otelworkshop.Product) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("product.name", product.Name),
+		attribute.String("product.color", product.Color),
+	}
+}
@@ -1,17 +1,61 @@
 package random
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	rand "math/rand/v2"
+	"sync"
 )
 
-func Item[T any](items []T) T {
-	return items[rand.IntN(len(items))]
+// Source is a random number generator that is safe for concurrent use.
+// Sources created with the same seed produce the same sequence, as long as
+// they are called in the same order.
+type Source struct {
+	mux  sync.Mutex
+	rand *rand.Rand
 }
 
-func Int(max int) int {
-	return rand.IntN(max)
+// New returns a Source seeded with seed, or with a random seed if seed is 0.
+func New(seed uint64) *Source {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+
+	return &Source{
+		rand: rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+func Item[T any](s *Source, items []T) T {
+	return items[s.Int(len(items))]
 }
 
-func Int64(max int64) int64 {
-	return rand.Int64N(max)
+func (s *Source) Int(max int) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.rand.IntN(max)
+}
+
+func (s *Source) Int64(max int64) int64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.rand.Int64N(max)
+}
+
+func (s *Source) Float64() float64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.rand.Float64()
+}
+
+// NewID returns a random 128-bit ID in hex, e.g. for orders and messages.
+// IDs must stay unique across runs, so they never come from a seeded Source.
+func NewID() string {
+	id := make([]byte, 16)
+	_, _ = crand.Read(id)
+
+	return hex.EncodeToString(id)
 }
@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"vinted/otel-workshop/internal/customer"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// PurchaseHistoryLength is how many of its latest purchases are kept per
+// customer.
+const PurchaseHistoryLength = 100
+
+// purchasesKey returns the purchase history key of the customer, or
+// customer.ErrInvalidID for IDs that could address other keys.
+func purchasesKey(customerID string) (string, error) {
+	if !customer.ValidID(customerID) {
+		return "", fmt.Errorf("%w: %q", customer.ErrInvalidID, customerID)
+	}
+
+	return "customer:" + customerID + ":purchases", nil
+}
+
+// countInWindow increments the KEYS[1] counter, starting a window of ARGV[1]
+// ms on the first increment. It returns {count, ms left in the window}.
+var countInWindow = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {count, redis.call("PTTL", KEYS[1])}
+`)
+
+type Purchase struct {
+	Name     string    `json:"name"`
+	Color    string    `json:"color"`
+	Quantity int64     `json:"quantity"`
+	Price    int64     `json:"price"`
+	Currency string    `json:"currency"`
+	Time     time.Time `json:"time"`
+}
+
+// AddPurchases records that the customer bought products at t, trimming the
+// history to PurchaseHistoryLength.
+func (r *WorkshopClient) AddPurchases(ctx context.Context, customerID string, t time.Time, products ...*otelworkshop.Product) error {
+	key, err := purchasesKey(customerID)
+	if err != nil {
+		return err
+	}
+
+	values := make([]any, len(products))
+	for i, p := range products {
+		value, err := json.Marshal(Purchase{
+			Name:     p.Name,
+			Color:    p.Color,
+			Quantity: p.Quantity,
+			Price:    p.Price,
+			Currency: p.Currency,
+			Time:     t,
+		})
+		if err != nil {
+			return err
+		}
+		values[i] = value
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, values...)
+		pipe.LTrim(ctx, key, 0, PurchaseHistoryLength-1)
+		return nil
+	})
+
+	return err
+}
+
+// Purchases returns up to limit of the customer's latest purchases, newest
+// first. A limit of zero returns all of them.
+func (r *WorkshopClient) Purchases(ctx context.Context, customerID string, limit int64) ([]Purchase, error) {
+	key, err := purchasesKey(customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := r.client.LRange(ctx, key, 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	purchases := make([]Purchase, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal([]byte(value), &purchases[i]); err != nil {
+			return nil, fmt.Errorf("decode purchase of %s: %w", customerID, err)
+		}
+	}
+
+	return purchases, nil
+}
+
+// Allow counts a call against the key limit of calls per window. It reports
+// whether the call is within the limit and, if not, how long until the
+// window resets.
+func (r *WorkshopClient) Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, time.Duration, error) {
+	result, err := countInWindow.Run(ctx, r.client, []string{key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	return result[0] <= limit, time.Duration(result[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vinted/otel-workshop/internal/customer"
+)
+
+func TestPurchasesRejectInvalidCustomerID(t *testing.T) {
+	client, mr := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.AddPurchases(ctx, "x:*", time.Now(), product("hat", "red", 1)); !errors.Is(err, customer.ErrInvalidID) {
+		t.Errorf("AddPurchases err = %v, want customer.ErrInvalidID", err)
+	}
+	if _, err := client.Purchases(ctx, "x:*", 0); !errors.Is(err, customer.ErrInvalidID) {
+		t.Errorf("Purchases err = %v, want customer.ErrInvalidID", err)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("keys = %v, want none", keys)
+	}
+
+	if err := client.AddPurchases(ctx, "c0123456789ab", time.Now(), product("hat", "red", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if purchases, err := client.Purchases(ctx, "c0123456789ab", 0); err != nil || len(purchases) != 1 {
+		t.Errorf("Purchases = %v, %v; want the hat", purchases, err)
+	}
+}
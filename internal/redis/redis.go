@@ -1,32 +1,163 @@
 package redis
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 	"vinted/otel-workshop/pb/genproto/otelworkshop"
 
 	redis "github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrInvalidQuantity rejects decrements that are not positive, which
+	// would otherwise add stock.
+	ErrInvalidQuantity = errors.New("quantity must be positive")
+)
+
+// InsufficientStockError reports the first product passed to DecrementMany
+// whose stock can't cover its decrement. It matches ErrInsufficientStock.
+type InsufficientStockError struct {
+	// Index of the product in the DecrementMany call.
+	Index int
+	// Available is what is left for the product after the products before
+	// it, which may name the same product.
+	Available int64
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %d: %d available", e.Index, e.Available)
+}
+
+func (e *InsufficientStockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+const (
+	// InventoryStream receives an entry for every stock change, so that
+	// readers can follow the inventory without polling every key.
+	InventoryStream = "inventory:changes"
+
+	inventoryStreamMaxLen = 10000
+)
+
+// decrementMany decrements each of KEYS[2..n] only if every current value
+// covers its decrement, and appends every change to the KEYS[1] stream.
+// ARGV[1] is the stream max length, followed by a name, color and decrement
+// per key; a key may repeat. It returns {1, remaining...} on success and
+// {0, position, available} for the first key that falls short.
+var decrementMany = redis.NewScript(`
+local needed = {}
+for i = 2, #KEYS do
+	local decrement = tonumber(ARGV[2 + (i - 2) * 3 + 2])
+	needed[KEYS[i]] = (needed[KEYS[i]] or 0) + decrement
+	local current = tonumber(redis.call("GET", KEYS[i]) or "0")
+	if current < needed[KEYS[i]] then
+		return {0, i - 1, current - needed[KEYS[i]] + decrement}
+	end
+end
+local result = {1}
+for i = 2, #KEYS do
+	local arg = 2 + (i - 2) * 3
+	local remaining = redis.call("DECRBY", KEYS[i], ARGV[arg + 2])
+	redis.call("XADD", KEYS[1], "MAXLEN", "~", ARGV[1], "*",
+		"name", ARGV[arg], "color", ARGV[arg + 1], "quantity", remaining, "delta", -tonumber(ARGV[arg + 2]))
+	result[#result + 1] = remaining
+end
+return result
+`)
+
+// incrementMany increments each of KEYS[2..n] and appends every change to
+// the KEYS[1] stream. ARGV[1] is the stream max length, followed by a name,
+// color and increment per key. It returns the new values.
+var incrementMany = redis.NewScript(`
+local quantities = {}
+for i = 2, #KEYS do
+	local arg = 2 + (i - 2) * 3
+	local quantity = redis.call("INCRBY", KEYS[i], ARGV[arg + 2])
+	redis.call("XADD", KEYS[1], "MAXLEN", "~", ARGV[1], "*",
+		"name", ARGV[arg], "color", ARGV[arg + 1], "quantity", quantity, "delta", ARGV[arg + 2])
+	quantities[#quantities + 1] = quantity
+end
+return quantities
+`)
+
+// incrementManyOnce increments each stock key by its increment and appends
+// the change to the KEYS[1] stream, skipping products whose dedupe key is
+// already set. Each product takes two keys, its dedupe key and its stock
+// key, from KEYS[2] on. ARGV[1] is the dedupe TTL in ms and ARGV[2] the
+// stream max length, followed by a name, color, increment and dedupe flag
+// per product; a flag of 0 disables deduplication for that product. It
+// returns 1 for every applied increment and 0 for every duplicate.
+var incrementManyOnce = redis.NewScript(`
+local applied = {}
+for i = 2, #KEYS, 2 do
+	local arg = 3 + (i - 2) * 2
+	if ARGV[arg + 3] == "1" and not redis.call("SET", KEYS[i], 1, "NX", "PX", ARGV[1]) then
+		applied[#applied + 1] = 0
+	else
+		local quantity = redis.call("INCRBY", KEYS[i + 1], ARGV[arg + 2])
+		redis.call("XADD", KEYS[1], "MAXLEN", "~", ARGV[2], "*",
+			"name", ARGV[arg], "color", ARGV[arg + 1], "quantity", quantity, "delta", ARGV[arg + 2])
+		applied[#applied + 1] = 1
+	end
+end
+return applied
+`)
+
+// Hook intercepts Redis commands, e.g. to inject faults.
+type Hook = redis.Hook
+
 type RedisClient interface {
+	redis.Scripter
 	DecrBy(ctx context.Context, key string, decrement int64) *redis.IntCmd
 	IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd
 	Get(ctx context.Context, key string) *redis.StringCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd
+	XRead(ctx context.Context, a *redis.XReadArgs) *redis.XStreamSliceCmd
+	ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd
+	LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	TxPipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+	Ping(ctx context.Context) *redis.StatusCmd
+	Close() error
 }
 
 type WorkshopClient struct {
 	client RedisClient
 }
 
-func NewWorkshopRedisClient(redisAddr string) *WorkshopClient {
+func NewWorkshopRedisClient(redisAddr string, hooks ...redis.Hook) *WorkshopClient {
 	client := redis.NewClient(&redis.Options{
 		Addr: redisAddr,
 	})
+	for _, hook := range hooks {
+		client.AddHook(hook)
+	}
+
+	return NewWorkshopClient(client)
+}
 
+// NewWorkshopClient wraps any RedisClient, e.g. a fake or a cluster client.
+func NewWorkshopClient(client RedisClient) *WorkshopClient {
 	return &WorkshopClient{
 		client: client,
 	}
 }
 
+func (r *WorkshopClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *WorkshopClient) Close() error {
+	return r.client.Close()
+}
+
 func key(product *otelworkshop.Product) string {
 	return product.Name + ":" + product.Color
 }
@@ -35,8 +166,151 @@ func (r *WorkshopClient) Decrement(ctx context.Context, product *otelworkshop.Pr
 	return r.client.DecrBy(ctx, key(product), decrement).Err()
 }
 
+// DecrementIfSufficient atomically decrements the product quantity unless
+// that would make it negative, in which case it returns ErrInsufficientStock.
+// The returned value is the remaining quantity on success and the available
+// quantity otherwise.
+func (r *WorkshopClient) DecrementIfSufficient(ctx context.Context, product *otelworkshop.Product, decrement int64) (int64, error) {
+	remaining, err := r.DecrementMany(ctx, []*otelworkshop.Product{{
+		Name:     product.Name,
+		Color:    product.Color,
+		Quantity: decrement,
+	}})
+
+	var insufficient *InsufficientStockError
+	if errors.As(err, &insufficient) {
+		return insufficient.Available, ErrInsufficientStock
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return remaining[0], nil
+}
+
+// DecrementMany takes the quantity of each product from its stock in a
+// single atomic step: either every product is decremented or, if any stock
+// falls short, none is and an *InsufficientStockError is returned. Changes
+// are published to InventoryStream. It returns the remaining quantities in
+// the same order.
+func (r *WorkshopClient) DecrementMany(ctx context.Context, products []*otelworkshop.Product) ([]int64, error) {
+	if len(products) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(products)+1)
+	args := make([]any, 0, len(products)*3+1)
+
+	keys = append(keys, InventoryStream)
+	args = append(args, inventoryStreamMaxLen)
+	for _, product := range products {
+		if product.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: %s %s: %d", ErrInvalidQuantity, product.Color, product.Name, product.Quantity)
+		}
+		keys = append(keys, key(product))
+		args = append(args, product.Name, product.Color, product.Quantity)
+	}
+
+	result, err := decrementMany.Run(ctx, r.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if result[0] == 0 {
+		return nil, &InsufficientStockError{
+			Index:     int(result[1]) - 1,
+			Available: result[2],
+		}
+	}
+
+	return result[1:], nil
+}
+
+// Increment adds value to the product quantity and publishes the change to
+// InventoryStream.
 func (r *WorkshopClient) Increment(ctx context.Context, product *otelworkshop.Product, value int64) error {
-	return r.client.IncrBy(ctx, key(product), value).Err()
+	_, err := r.IncrementMany(ctx, []*otelworkshop.Product{{
+		Name:     product.Name,
+		Color:    product.Color,
+		Quantity: value,
+	}})
+
+	return err
+}
+
+// IncrementOnce is Increment made idempotent by id: calls with an id seen in
+// the last ttl do nothing and return false.
+func (r *WorkshopClient) IncrementOnce(ctx context.Context, id string, ttl time.Duration, product *otelworkshop.Product, value int64) (bool, error) {
+	applied, err := r.IncrementManyOnce(ctx, []string{id}, ttl, []*otelworkshop.Product{{
+		Name:     product.Name,
+		Color:    product.Color,
+		Quantity: value,
+	}})
+	if err != nil {
+		return false, err
+	}
+
+	return applied[0], nil
+}
+
+// IncrementManyOnce is IncrementMany made idempotent by ids, one per
+// product: products whose id was seen in the last ttl are skipped. An empty
+// id disables deduplication for its product. It reports which increments
+// were applied, in the same order.
+func (r *WorkshopClient) IncrementManyOnce(ctx context.Context, ids []string, ttl time.Duration, products []*otelworkshop.Product) ([]bool, error) {
+	if len(products) != len(ids) {
+		return nil, fmt.Errorf("got %d ids for %d products", len(ids), len(products))
+	}
+	if len(products) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(products)*2+1)
+	args := make([]any, 0, len(products)*4+2)
+
+	keys = append(keys, InventoryStream)
+	args = append(args, ttl.Milliseconds(), inventoryStreamMaxLen)
+	for i, product := range products {
+		dedupe := 1
+		if ids[i] == "" {
+			dedupe = 0
+		}
+		keys = append(keys, "dedupe:"+ids[i], key(product))
+		args = append(args, product.Name, product.Color, product.Quantity, dedupe)
+	}
+
+	result, err := incrementManyOnce.Run(ctx, r.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]bool, len(result))
+	for i, flag := range result {
+		applied[i] = flag == 1
+	}
+
+	return applied, nil
+}
+
+// IncrementMany adds the quantity of each product to its stock in a single
+// round trip, publishing every change to InventoryStream. It returns the new
+// quantities in the same order.
+func (r *WorkshopClient) IncrementMany(ctx context.Context, products []*otelworkshop.Product) ([]int64, error) {
+	if len(products) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(products)+1)
+	args := make([]any, 0, len(products)*3+1)
+
+	keys = append(keys, InventoryStream)
+	args = append(args, inventoryStreamMaxLen)
+	for _, product := range products {
+		keys = append(keys, key(product))
+		args = append(args, product.Name, product.Color, product.Quantity)
+	}
+
+	return incrementMany.Run(ctx, r.client, keys, args...).Int64Slice()
 }
 
 func (r *WorkshopClient) GetValue(ctx context.Context, product *otelworkshop.Product) (int64, error) {
@@ -47,3 +321,172 @@ func (r *WorkshopClient) GetValue(ctx context.Context, product *otelworkshop.Pro
 
 	return value, nil
 }
+
+// GetValues returns the quantities of products in a single round trip.
+// Missing keys count as zero.
+func (r *WorkshopClient) GetValues(ctx context.Context, products []*otelworkshop.Product) ([]int64, error) {
+	keys := productKeys(products)
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseValues(keys, values)
+}
+
+// SnapshotValues is GetValues that also returns the ID of the last
+// InventoryStream entry the quantities reflect, read in the same
+// transaction. Changes after that ID apply on top of the snapshot.
+func (r *WorkshopClient) SnapshotValues(ctx context.Context, products []*otelworkshop.Product) ([]int64, string, error) {
+	keys := productKeys(products)
+
+	var last *redis.XMessageSliceCmd
+	var values *redis.SliceCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		last = pipe.XRevRangeN(ctx, InventoryStream, "+", "-", 1)
+		values = pipe.MGet(ctx, keys...)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	quantities, err := parseValues(keys, values.Val())
+	if err != nil {
+		return nil, "", err
+	}
+
+	id := "0-0"
+	if messages := last.Val(); len(messages) > 0 {
+		id = messages[0].ID
+	}
+
+	return quantities, id, nil
+}
+
+func productKeys(products []*otelworkshop.Product) []string {
+	keys := make([]string, len(products))
+	for i, product := range products {
+		keys[i] = key(product)
+	}
+
+	return keys
+}
+
+func parseValues(keys []string, values []any) ([]int64, error) {
+	quantities := make([]int64, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value %v for %s", value, keys[i])
+		}
+
+		var err error
+		quantities[i], err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", keys[i], err)
+		}
+	}
+
+	return quantities, nil
+}
+
+// CompareStreamIDs orders two stream IDs like Redis does, returning -1, 0
+// or +1. An empty ID sorts before every other.
+func CompareStreamIDs(a, b string) int {
+	ams, aseq := splitStreamID(a)
+	bms, bseq := splitStreamID(b)
+
+	if c := cmp.Compare(ams, bms); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(aseq, bseq)
+}
+
+func splitStreamID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	m, _ := strconv.ParseUint(ms, 10, 64)
+	s, _ := strconv.ParseUint(seq, 10, 64)
+
+	return m, s
+}
+
+// StockChange is an InventoryStream entry. Product holds the quantity after
+// the change.
+type StockChange struct {
+	ID      string
+	Product *otelworkshop.Product
+	Delta   int64
+	Time    time.Time
+}
+
+// ReadStockChanges blocks for up to block waiting for changes after the
+// stream ID after, which may be "$" for changes that have not happened yet.
+// It returns no changes and no error if none arrived in time.
+func (r *WorkshopClient) ReadStockChanges(ctx context.Context, after string, block time.Duration) ([]StockChange, error) {
+	streams, err := r.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{InventoryStream, after},
+		Block:   block,
+		Count:   100,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []StockChange
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			change, err := parseStockChange(message)
+			if err != nil {
+				return nil, fmt.Errorf("parse stream entry %s: %w", message.ID, err)
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+func parseStockChange(message redis.XMessage) (StockChange, error) {
+	field := func(name string) string {
+		value, _ := message.Values[name].(string)
+		return value
+	}
+
+	quantity, err := strconv.ParseInt(field("quantity"), 10, 64)
+	if err != nil {
+		return StockChange{}, fmt.Errorf("quantity: %w", err)
+	}
+
+	delta, err := strconv.ParseInt(field("delta"), 10, 64)
+	if err != nil {
+		return StockChange{}, fmt.Errorf("delta: %w", err)
+	}
+
+	// Stream IDs start with the Redis server time in milliseconds.
+	ms, _, _ := strings.Cut(message.ID, "-")
+	millis, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return StockChange{}, fmt.Errorf("id: %w", err)
+	}
+
+	return StockChange{
+		ID: message.ID,
+		Product: &otelworkshop.Product{
+			Name:     field("name"),
+			Color:    field("color"),
+			Quantity: quantity,
+		},
+		Delta: delta,
+		Time:  time.UnixMilli(millis),
+	}, nil
+}
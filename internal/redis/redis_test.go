@@ -0,0 +1,252 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+)
+
+func newTestClient(t testing.TB) (*WorkshopClient, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := NewWorkshopClient(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	t.Cleanup(func() { client.Close() })
+
+	return client, mr
+}
+
+func product(name, color string, quantity int64) *otelworkshop.Product {
+	return &otelworkshop.Product{Name: name, Color: color, Quantity: quantity}
+}
+
+func TestDecrementIfSufficient(t *testing.T) {
+	client, mr := newTestClient(t)
+	ctx := context.Background()
+	mr.Set("hat:red", "5")
+
+	remaining, err := client.DecrementIfSufficient(ctx, product("hat", "red", 0), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 2 {
+		t.Errorf("remaining = %d, want 2", remaining)
+	}
+
+	available, err := client.DecrementIfSufficient(ctx, product("hat", "red", 0), 3)
+	if !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("err = %v, want ErrInsufficientStock", err)
+	}
+	if available != 2 {
+		t.Errorf("available = %d, want 2", available)
+	}
+
+	if got, _ := mr.Get("hat:red"); got != "2" {
+		t.Errorf("hat:red = %s, want 2", got)
+	}
+}
+
+func TestDecrementRejectsNonPositiveQuantity(t *testing.T) {
+	client, mr := newTestClient(t)
+	ctx := context.Background()
+	mr.Set("hat:red", "5")
+
+	for _, quantity := range []int64{0, -3} {
+		_, err := client.DecrementIfSufficient(ctx, product("hat", "red", 0), quantity)
+		if !errors.Is(err, ErrInvalidQuantity) {
+			t.Errorf("decrement %d: err = %v, want ErrInvalidQuantity", quantity, err)
+		}
+	}
+
+	if got, _ := mr.Get("hat:red"); got != "5" {
+		t.Errorf("hat:red = %s, want 5", got)
+	}
+	if mr.Exists(InventoryStream) {
+		t.Error("rejected decrements were published")
+	}
+}
+
+func TestDecrementManyIsAtomic(t *testing.T) {
+	client, mr := newTestClient(t)
+	ctx := context.Background()
+	mr.Set("hat:red", "5")
+	mr.Set("sock:blue", "1")
+
+	// The same product twice must be covered by the sum of both lines.
+	_, err := client.DecrementMany(ctx, []*otelworkshop.Product{
+		product("sock", "blue", 1),
+		product("hat", "red", 3),
+		product("hat", "red", 3),
+	})
+	var insufficient *InsufficientStockError
+	if !errors.As(err, &insufficient) {
+		t.Fatalf("err = %v, want *InsufficientStockError", err)
+	}
+	if insufficient.Index != 2 || insufficient.Available != 2 {
+		t.Errorf("got index %d available %d, want index 2 available 2", insufficient.Index, insufficient.Available)
+	}
+	if got, _ := mr.Get("sock:blue"); got != "1" {
+		t.Errorf("sock:blue = %s, want 1 after a failed checkout", got)
+	}
+
+	remaining, err := client.DecrementMany(ctx, []*otelworkshop.Product{
+		product("sock", "blue", 1),
+		product("hat", "red", 2),
+		product("hat", "red", 3),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{0, 3, 0}; !slices.Equal(remaining, want) {
+		t.Errorf("remaining = %v, want %v", remaining, want)
+	}
+}
+
+func TestDecrementManyPublishesChanges(t *testing.T) {
+	client, mr := newTestClient(t)
+	ctx := context.Background()
+	mr.Set("hat:red", "5")
+
+	if _, err := client.DecrementMany(ctx, []*otelworkshop.Product{product("hat", "red", 2)}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := client.ReadStockChanges(ctx, "0", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(changes))
+	}
+	change := changes[0]
+	if change.Product.Name != "hat" || change.Product.Color != "red" || change.Product.Quantity != 3 || change.Delta != -2 {
+		t.Errorf("change = %+v %+v, want red hat at 3 with delta -2", change, change.Product)
+	}
+}
+
+func TestCompareStreamIDs(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1-0", "1-0", 0},
+		{"1-1", "1-0", 1},
+		{"9-0", "10-0", -1},
+		{"", "0-1", -1},
+	}
+
+	for _, tt := range tests {
+		if got := CompareStreamIDs(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareStreamIDs(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestIncrementManyOnce(t *testing.T) {
+	client, mr := newTestClient(t)
+	ctx := context.Background()
+
+	products := []*otelworkshop.Product{
+		product("hat", "red", 1),
+		product("hat", "red", 1),
+		product("sock", "blue", 2),
+	}
+
+	applied, err := client.IncrementManyOnce(ctx, []string{"a", "b", ""}, time.Minute, products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{true, true, true}; !slices.Equal(applied, want) {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+
+	// Replaying the same messages only applies the one without an id.
+	applied, err = client.IncrementManyOnce(ctx, []string{"a", "b", ""}, time.Minute, products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{false, false, true}; !slices.Equal(applied, want) {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+
+	if got, _ := mr.Get("hat:red"); got != "2" {
+		t.Errorf("hat:red = %s, want 2", got)
+	}
+	if got, _ := mr.Get("sock:blue"); got != "4" {
+		t.Errorf("sock:blue = %s, want 4", got)
+	}
+}
+
+func benchmarkProducts(n int) []*otelworkshop.Product {
+	products := make([]*otelworkshop.Product, n)
+	for i := range products {
+		products[i] = product("hat", strconv.Itoa(i), 1)
+	}
+
+	return products
+}
+
+// The Increment and GetValue benchmarks make one round trip per product,
+// their Many counterparts one per call.
+func BenchmarkIncrement(b *testing.B) {
+	client, _ := newTestClient(b)
+	ctx := context.Background()
+	products := benchmarkProducts(25)
+
+	b.ResetTimer()
+	for range b.N {
+		for _, p := range products {
+			if err := client.Increment(ctx, p, 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkIncrementMany(b *testing.B) {
+	client, _ := newTestClient(b)
+	ctx := context.Background()
+	products := benchmarkProducts(25)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := client.IncrementMany(ctx, products); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetValue(b *testing.B) {
+	client, _ := newTestClient(b)
+	ctx := context.Background()
+	products := benchmarkProducts(25)
+
+	b.ResetTimer()
+	for range b.N {
+		for _, p := range products {
+			if _, err := client.GetValue(ctx, p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkGetValues(b *testing.B) {
+	client, _ := newTestClient(b)
+	ctx := context.Background()
+	products := benchmarkProducts(25)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := client.GetValues(ctx, products); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationExpired  = errors.New("reservation expired")
+)
+
+// reservationDeadlines is a sorted set of reservation IDs scored by their
+// expiry in Unix milliseconds. Reservation hashes have no TTL of their own:
+// the reserved stock must be returned before a reservation is forgotten.
+const reservationDeadlines = "reservations:deadlines"
+
+func reservationKey(id string) string {
+	return "reservation:" + id
+}
+
+// reserve decrements the KEYS[1] stock by ARGV[1] if it covers it, appends
+// the change to the KEYS[2] stream, stores the reservation in the KEYS[3]
+// hash and adds ARGV[5] to the KEYS[4] deadlines at ARGV[6]. ARGV[2..4] are
+// the name, color and stream max length, ARGV[7..9] the price, currency and
+// link. It returns {1, remaining} on success and {0, current} otherwise.
+var reserve = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local quantity = tonumber(ARGV[1])
+if current < quantity then
+	return {0, current}
+end
+local remaining = redis.call("DECRBY", KEYS[1], quantity)
+redis.call("XADD", KEYS[2], "MAXLEN", "~", ARGV[4], "*",
+	"name", ARGV[2], "color", ARGV[3], "quantity", remaining, "delta", -quantity)
+redis.call("HSET", KEYS[3], "name", ARGV[2], "color", ARGV[3], "quantity", quantity,
+	"price", ARGV[7], "currency", ARGV[8], "expires_at", ARGV[6], "link", ARGV[9])
+redis.call("ZADD", KEYS[4], ARGV[6], ARGV[5])
+return {1, remaining}
+`)
+
+// confirmReservation deletes the KEYS[1] reservation and its ARGV[1] entry
+// in the KEYS[2] deadlines unless it expired before ARGV[2]. It returns {1,
+// name, color, quantity, price, currency, expires_at, link} on success, {0}
+// if the reservation doesn't exist and {-1} if it expired.
+var confirmReservation = redis.NewScript(`
+local r = redis.call("HMGET", KEYS[1], "name", "color", "quantity", "price", "currency", "expires_at", "link")
+if not r[1] then
+	return {0}
+end
+if tonumber(r[6]) <= tonumber(ARGV[2]) then
+	return {-1}
+end
+redis.call("DEL", KEYS[1])
+redis.call("ZREM", KEYS[2], ARGV[1])
+return {1, r[1], r[2], r[3], r[4], r[5], r[6], r[7]}
+`)
+
+// releaseReservation returns the KEYS[1] reservation to the KEYS[4] stock,
+// appending the change to the KEYS[3] stream, and deletes it along with its
+// ARGV[1] entry in the KEYS[2] deadlines. ARGV[2] is the stream max length.
+// If ARGV[3] is set, only a reservation that expired before it is released.
+// It returns {1, name, color, quantity, price, currency, expires_at, link} if
+// the reservation was released and {0} otherwise.
+var releaseReservation = redis.NewScript(`
+local r = redis.call("HMGET", KEYS[1], "name", "color", "quantity", "price", "currency", "expires_at", "link")
+if not r[1] then
+	return {0}
+end
+if ARGV[3] ~= "" and tonumber(r[6]) > tonumber(ARGV[3]) then
+	return {0}
+end
+local remaining = redis.call("INCRBY", KEYS[4], r[3])
+redis.call("XADD", KEYS[3], "MAXLEN", "~", ARGV[2], "*",
+	"name", r[1], "color", r[2], "quantity", remaining, "delta", r[3])
+redis.call("DEL", KEYS[1])
+redis.call("ZREM", KEYS[2], ARGV[1])
+return {1, r[1], r[2], r[3], r[4], r[5], r[6], r[7]}
+`)
+
+// Reservation holds Product.Quantity items out of stock until ExpiresAt.
+type Reservation struct {
+	ID        string
+	Product   *otelworkshop.Product
+	ExpiresAt time.Time
+	// Link identifies the span that made the reservation, as a W3C
+	// traceparent, so that later spans can link to it.
+	Link string
+}
+
+// Reserve takes the reserved quantity out of stock and stores r, unless the
+// stock can't cover it, in which case it returns ErrInsufficientStock. The
+// returned value is the remaining quantity on success and the available
+// quantity otherwise.
+func (r *WorkshopClient) Reserve(ctx context.Context, reservation *Reservation) (int64, error) {
+	p := reservation.Product
+	if p.Quantity <= 0 {
+		return 0, fmt.Errorf("%w: %s %s: %d", ErrInvalidQuantity, p.Color, p.Name, p.Quantity)
+	}
+
+	result, err := reserve.Run(ctx, r.client,
+		[]string{key(p), InventoryStream, reservationKey(reservation.ID), reservationDeadlines},
+		p.Quantity, p.Name, p.Color, inventoryStreamMaxLen,
+		reservation.ID, reservation.ExpiresAt.UnixMilli(), p.Price, p.Currency, reservation.Link,
+	).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+
+	if result[0] == 0 {
+		return result[1], ErrInsufficientStock
+	}
+
+	return result[1], nil
+}
+
+// ConfirmReservation turns the reservation into a sale and forgets it. It
+// returns ErrReservationNotFound for unknown, confirmed or released
+// reservations and ErrReservationExpired for ones past their expiry that
+// are yet to be released.
+func (r *WorkshopClient) ConfirmReservation(ctx context.Context, id string, now time.Time) (*Reservation, error) {
+	result, err := confirmReservation.Run(ctx, r.client,
+		[]string{reservationKey(id), reservationDeadlines},
+		id, now.UnixMilli(),
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	switch result[0] {
+	case int64(0):
+		return nil, ErrReservationNotFound
+	case int64(-1):
+		return nil, ErrReservationExpired
+	}
+
+	return parseReservation(id, result[1:])
+}
+
+// CancelReservation returns the reserved quantity to stock and forgets the
+// reservation, which it returns, or returns ErrReservationNotFound.
+func (r *WorkshopClient) CancelReservation(ctx context.Context, id string) (*Reservation, error) {
+	reservation, err := r.releaseReservation(ctx, id, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if reservation == nil {
+		return nil, ErrReservationNotFound
+	}
+
+	return reservation, nil
+}
+
+// ReleaseExpiredReservations returns up to limit reservations that expired
+// before now to stock. It returns the reservations it released.
+func (r *WorkshopClient) ReleaseExpiredReservations(ctx context.Context, now time.Time, limit int64) ([]*Reservation, error) {
+	deadline := strconv.FormatInt(now.UnixMilli(), 10)
+
+	ids, err := r.client.ZRangeByScore(ctx, reservationDeadlines, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   deadline,
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var released []*Reservation
+	for _, id := range ids {
+		reservation, err := r.releaseReservation(ctx, id, deadline)
+		if err != nil {
+			return released, err
+		}
+		if reservation != nil {
+			released = append(released, reservation)
+		}
+	}
+
+	return released, nil
+}
+
+// releaseReservation returns the released reservation, or nil if there was
+// nothing to release. The stock key is looked up before running the script
+// so that every key the script touches is declared in KEYS; reservations
+// are never modified, only deleted, so the lookup can't go stale.
+func (r *WorkshopClient) releaseReservation(ctx context.Context, id, expiredBefore string) (*Reservation, error) {
+	fields, err := r.client.HMGet(ctx, reservationKey(id), "name", "color").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	name, ok := fields[0].(string)
+	if !ok {
+		return nil, nil
+	}
+	color, _ := fields[1].(string)
+
+	result, err := releaseReservation.Run(ctx, r.client,
+		[]string{reservationKey(id), reservationDeadlines, InventoryStream, key(&otelworkshop.Product{Name: name, Color: color})},
+		id, inventoryStreamMaxLen, expiredBefore,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if result[0] != int64(1) {
+		return nil, nil
+	}
+
+	return parseReservation(id, result[1:])
+}
+
+// parseReservation builds reservation id from the name, color, quantity,
+// price, currency, expires_at and link values returned by the scripts.
+func parseReservation(id string, values []any) (*Reservation, error) {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		field, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value %v in reservation %s", value, id)
+		}
+		fields[i] = field
+	}
+
+	quantity, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse reservation %s quantity: %w", id, err)
+	}
+
+	price, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse reservation %s price: %w", id, err)
+	}
+
+	expiresAt, err := strconv.ParseInt(fields[5], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse reservation %s expiry: %w", id, err)
+	}
+
+	return &Reservation{
+		ID: id,
+		Product: &otelworkshop.Product{
+			Name:     fields[0],
+			Color:    fields[1],
+			Quantity: quantity,
+			Price:    price,
+			Currency: fields[4],
+		},
+		ExpiresAt: time.UnixMilli(expiresAt),
+		Link:      fields[6],
+	}, nil
+}
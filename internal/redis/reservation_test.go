@@ -0,0 +1,67 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCancelReservation(t *testing.T) {
+	client, mr := newTestClient(t)
+	ctx := context.Background()
+	mr.Set("hat:red", "5")
+
+	p := product("hat", "red", 2)
+	p.Price, p.Currency = 100, "EUR"
+	if _, err := client.Reserve(ctx, &Reservation{ID: "r1", Product: p, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	reservation, err := client.CancelReservation(ctx, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reservation.Product; got.Name != "hat" || got.Color != "red" || got.Quantity != 2 || got.Price != 100 {
+		t.Errorf("cancelled %v, want the reserved product", got)
+	}
+	if got, _ := mr.Get("hat:red"); got != "5" {
+		t.Errorf("hat:red = %s, want 5", got)
+	}
+
+	if _, err := client.CancelReservation(ctx, "r1"); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("err = %v cancelling twice, want ErrReservationNotFound", err)
+	}
+}
+
+func TestReleaseExpiredReservations(t *testing.T) {
+	client, mr := newTestClient(t)
+	ctx := context.Background()
+	mr.Set("hat:red", "5")
+	mr.Set("socks:blue", "5")
+
+	now := time.Now()
+	reservations := []*Reservation{
+		{ID: "expired", Product: product("hat", "red", 2), ExpiresAt: now.Add(-time.Minute)},
+		{ID: "live", Product: product("socks", "blue", 3), ExpiresAt: now.Add(time.Hour)},
+	}
+	for _, reservation := range reservations {
+		if _, err := client.Reserve(ctx, reservation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	released, err := client.ReleaseExpiredReservations(ctx, now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 || released[0].ID != "expired" || released[0].Product.Name != "hat" {
+		t.Fatalf("released %v, want only the expired hat reservation", released)
+	}
+	if got, _ := mr.Get("hat:red"); got != "5" {
+		t.Errorf("hat:red = %s, want 5", got)
+	}
+	if got, _ := mr.Get("socks:blue"); got != "2" {
+		t.Errorf("socks:blue = %s, want 2", got)
+	}
+}
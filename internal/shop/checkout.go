@@ -0,0 +1,124 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/redis"
+	"vinted/otel-workshop/internal/telemetry"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *RedisShop) Checkout(ctx context.Context, req *otelworkshop.CheckoutRequest) (*otelworkshop.CheckoutResponse, error) {
+	s.logger.Info("checking out", telemetry.ZapContext(ctx), zap.String("name", req.Name), zap.String("surname", req.Surname), zap.Int("lines", len(req.Lines)))
+
+	if err := s.limitCustomer(ctx); err != nil {
+		return nil, err
+	}
+
+	lines, err := s.priceLines(ctx, req.Lines)
+	var invalid *catalog.ValidationError
+	if errors.As(err, &invalid) {
+		s.logger.Warn("invalid checkout", telemetry.ZapContext(ctx), zap.Error(invalid))
+		return nil, invalid
+	}
+	if err != nil {
+		s.logger.Error("failed to validate checkout", telemetry.ZapContext(ctx), zap.Error(err))
+		return nil, status.Errorf(codes.Unavailable, "validate checkout: %v", err)
+	}
+
+	_, err = s.redisClient.DecrementMany(ctx, lines)
+	var insufficient *redis.InsufficientStockError
+	if errors.As(err, &insufficient) {
+		line := lines[insufficient.Index]
+		s.logger.Warn("insufficient stock", telemetry.ZapContext(ctx), zap.Any("product", line), zap.Int64("available", insufficient.Available))
+		return nil, insufficientStockError(line, insufficient.Available)
+	}
+	if err != nil {
+		s.logger.Error("failed to decrement product quantities", telemetry.ZapContext(ctx), zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "decrement product quantities: %v", err)
+	}
+
+	resp := &otelworkshop.CheckoutResponse{
+		Lines:    lines,
+		Currency: lines[0].Currency,
+	}
+	for _, line := range lines {
+		resp.Total += line.Quantity * line.Price
+		s.recordSale(ctx, line)
+	}
+	s.recordPurchases(ctx, lines...)
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("checkout.lines", len(lines)),
+		attribute.Int64("checkout.total", resp.Total),
+		attribute.String("checkout.currency", resp.Currency),
+	)
+
+	s.logger.Info("checked out", telemetry.ZapContext(ctx), zap.String("name", req.Name), zap.String("surname", req.Surname), zap.Int64("total", resp.Total), zap.String("currency", resp.Currency))
+
+	return resp, nil
+}
+
+// priceLines validates every line against the catalog and returns them with
+// their unit prices. All lines must share a currency so that they add up to
+// a single total, and the lines for a product must not add up to more than
+// its max order quantity.
+func (s *RedisShop) priceLines(ctx context.Context, lines []*otelworkshop.Product) ([]*otelworkshop.Product, error) {
+	invalid := &catalog.ValidationError{}
+	if len(lines) == 0 {
+		invalid.Add("lines", "must not be empty")
+		return nil, invalid
+	}
+
+	var currency, currencyField string
+	// Lines for the same product share its max order quantity, so that
+	// splitting an order across lines doesn't get around it.
+	totals := make(map[string]int64)
+	result := make([]*otelworkshop.Product, len(lines))
+	for i, line := range lines {
+		field := fmt.Sprintf("lines[%d]", i)
+
+		item, err := catalog.Validate(ctx, s.catalog, line)
+		var lineInvalid *catalog.ValidationError
+		if errors.As(err, &lineInvalid) {
+			for _, v := range lineInvalid.Violations {
+				invalid.Add(field+"."+v.Field, "%s", v.Description)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if currency == "" {
+			currency, currencyField = item.Currency, field
+		} else if item.Currency != currency {
+			invalid.Add(field, "%s is priced in %s, not %s like %s", item.Name, item.Currency, currency, currencyField)
+			continue
+		}
+
+		key := line.Name + ":" + line.Color
+		totals[key] += line.Quantity
+		if total := totals[key]; total > item.MaxOrderQuantity && total-line.Quantity <= item.MaxOrderQuantity {
+			invalid.Add(field+".quantity", "lines for %s %s add up to %d, must not exceed %d", line.Color, line.Name, total, item.MaxOrderQuantity)
+			continue
+		}
+
+		result[i] = priced(line, item)
+	}
+
+	if len(invalid.Violations) > 0 {
+		return nil, invalid
+	}
+
+	return result, nil
+}
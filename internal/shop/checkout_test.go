@@ -0,0 +1,51 @@
+package shop
+
+import (
+	"context"
+	"testing"
+
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckoutSumsLinesPerProduct(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.Set("hat:red", "20")
+
+	cat := catalog.NewMemoryCatalog(catalog.Item{Name: "hat", Colors: []string{"red", "blue"}, MaxOrderQuantity: 5, Price: 100, Currency: "EUR"})
+	s := NewRedisShop(zap.NewNop(), mr.Addr(), cat, Config{})
+	defer s.Close()
+
+	line := func(color string, quantity int64) *otelworkshop.Product {
+		return &otelworkshop.Product{Name: "hat", Color: color, Quantity: quantity}
+	}
+
+	_, err := s.Checkout(context.Background(), &otelworkshop.CheckoutRequest{
+		Lines: []*otelworkshop.Product{line("red", 3), line("red", 3)},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+	if got, _ := mr.Get("hat:red"); got != "20" {
+		t.Errorf("hat:red = %s after a rejected checkout, want 20", got)
+	}
+
+	mr.Set("hat:blue", "20")
+	resp, err := s.Checkout(context.Background(), &otelworkshop.CheckoutRequest{
+		Lines: []*otelworkshop.Product{line("red", 3), line("blue", 3), line("red", 2)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 800 {
+		t.Errorf("total = %d, want 800", resp.Total)
+	}
+	if got, _ := mr.Get("hat:red"); got != "15" {
+		t.Errorf("hat:red = %s, want 15", got)
+	}
+}
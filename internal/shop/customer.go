@@ -0,0 +1,107 @@
+package shop
+
+import (
+	"context"
+	"time"
+
+	"vinted/otel-workshop/internal/customer"
+	"vinted/otel-workshop/internal/telemetry"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// limitCustomer rejects the call with ResourceExhausted if the customer in
+// the ctx baggage is over their rate limit, and with InvalidArgument if the
+// customer ID is malformed. Calls without a customer are not limited, and
+// neither are calls made while Redis can't tell.
+func (s *RedisShop) limitCustomer(ctx context.Context) error {
+	id := customer.IDFromContext(ctx)
+	if id == "" {
+		return nil
+	}
+	if !customer.ValidID(id) {
+		s.logger.Warn("invalid customer ID", telemetry.ZapContext(ctx), zap.String("customer_id", id))
+		return status.Errorf(codes.InvalidArgument, "%v: %q", customer.ErrInvalidID, id)
+	}
+	if s.config.CustomerRateLimit == 0 {
+		return nil
+	}
+
+	allowed, retry, err := s.redisClient.Allow(ctx, "ratelimit:customer:"+id, s.config.CustomerRateLimit, s.config.CustomerRateWindow)
+	if err != nil {
+		s.logger.Warn("failed to check customer rate limit", telemetry.ZapContext(ctx), zap.String("customer_id", id), zap.Error(err))
+		return nil
+	}
+	if allowed {
+		return nil
+	}
+
+	s.limited.Add(ctx, 1)
+	s.logger.Warn("customer rate limited", telemetry.ZapContext(ctx), zap.String("customer_id", id), zap.Duration("retry_after", retry))
+
+	st := status.Newf(codes.ResourceExhausted, "customer %s exceeded %d calls per %s", id, s.config.CustomerRateLimit, s.config.CustomerRateWindow)
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retry),
+	})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// recordPurchases adds products to the purchase history of the customer in
+// the ctx baggage. The sale has already happened, so failures are only
+// logged.
+func (s *RedisShop) recordPurchases(ctx context.Context, products ...*otelworkshop.Product) {
+	id := customer.IDFromContext(ctx)
+	if id == "" {
+		return
+	}
+
+	if err := s.redisClient.AddPurchases(ctx, id, time.Now(), products...); err != nil {
+		s.logger.Warn("failed to record purchase history", telemetry.ZapContext(ctx), zap.String("customer_id", id), zap.Error(err))
+	}
+}
+
+func (s *RedisShop) GetPurchaseHistory(ctx context.Context, req *otelworkshop.GetPurchaseHistoryRequest) (*otelworkshop.PurchaseHistory, error) {
+	if req.CustomerId == "" {
+		return nil, status.Error(codes.InvalidArgument, "customer_id is required")
+	}
+	if !customer.ValidID(req.CustomerId) {
+		return nil, status.Errorf(codes.InvalidArgument, "%v: %q", customer.ErrInvalidID, req.CustomerId)
+	}
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+
+	purchases, err := s.redisClient.Purchases(ctx, req.CustomerId, int64(req.Limit))
+	if err != nil {
+		s.logger.Error("failed to get purchase history", telemetry.ZapContext(ctx), zap.String("customer_id", req.CustomerId), zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "get purchase history: %v", err)
+	}
+
+	history := &otelworkshop.PurchaseHistory{
+		Purchases: make([]*otelworkshop.Purchase, len(purchases)),
+	}
+	for i, p := range purchases {
+		history.Purchases[i] = &otelworkshop.Purchase{
+			Product: &otelworkshop.Product{
+				Name:     p.Name,
+				Color:    p.Color,
+				Quantity: p.Quantity,
+				Price:    p.Price,
+				Currency: p.Currency,
+			},
+			PurchasedAt: timestamppb.New(p.Time),
+		}
+	}
+
+	return history, nil
+}
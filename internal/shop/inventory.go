@@ -0,0 +1,136 @@
+package shop
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"sync"
+
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+)
+
+// watcherBuffer is how many changes a watcher can lag behind before it is
+// disconnected.
+const watcherBuffer = 64
+
+var (
+	errWatcherLagging = errors.New("watcher fell behind inventory changes")
+	errShopClosed     = errors.New("shop is shutting down")
+)
+
+func matches(filter *otelworkshop.ProductFilter, p *otelworkshop.Product) bool {
+	if filter == nil {
+		return true
+	}
+
+	if filter.Name != "" && filter.Name != p.Name {
+		return false
+	}
+
+	if filter.Color != "" && filter.Color != p.Color {
+		return false
+	}
+
+	if filter.MinQuantity != nil && p.Quantity < *filter.MinQuantity {
+		return false
+	}
+
+	return true
+}
+
+// Page tokens are opaque to clients but simply encode the offset into the
+// filtered inventory, which keeps a stable order between updates.
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	return offset, nil
+}
+
+type watcher struct {
+	filter  *otelworkshop.ProductFilter
+	changes chan *otelworkshop.InventoryChange
+	// err is set before changes is closed.
+	err error
+}
+
+type watchers struct {
+	mux sync.Mutex
+	set map[*watcher]struct{}
+}
+
+func (w *watchers) add(filter *otelworkshop.ProductFilter) *watcher {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if w.set == nil {
+		w.set = make(map[*watcher]struct{})
+	}
+
+	watcher := &watcher{
+		filter:  filter,
+		changes: make(chan *otelworkshop.InventoryChange, watcherBuffer),
+	}
+	w.set[watcher] = struct{}{}
+
+	return watcher
+}
+
+func (w *watchers) remove(watcher *watcher) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	delete(w.set, watcher)
+}
+
+// publish sends change to every matching watcher without blocking.
+// Watchers whose buffer is full are disconnected.
+func (w *watchers) publish(change *otelworkshop.InventoryChange) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	for watcher := range w.set {
+		if !matches(watcher.filter, change.Product) {
+			continue
+		}
+
+		select {
+		case watcher.changes <- change:
+		default:
+			w.closeLocked(watcher, errWatcherLagging)
+		}
+	}
+}
+
+func (w *watchers) closeAll(err error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	for watcher := range w.set {
+		w.closeLocked(watcher, err)
+	}
+}
+
+func (w *watchers) closeLocked(watcher *watcher, err error) {
+	watcher.err = err
+	close(watcher.changes)
+	delete(w.set, watcher)
+}
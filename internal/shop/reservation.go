@@ -0,0 +1,166 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/product"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/internal/redis"
+	"vinted/otel-workshop/internal/telemetry"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	releaseBatchSize  = 100
+	traceparentHeader = "traceparent"
+)
+
+func (s *RedisShop) ReserveProduct(ctx context.Context, req *otelworkshop.ReserveProductRequest) (*otelworkshop.Reservation, error) {
+	s.logger.Info("reserving product", telemetry.ZapContext(ctx), zap.String("name", req.Name), zap.String("surname", req.Surname), zap.Any("product", req.Product))
+
+	if req.Product == nil {
+		return nil, status.Error(codes.InvalidArgument, "product is required")
+	}
+
+	if err := s.limitCustomer(ctx); err != nil {
+		return nil, err
+	}
+
+	item, err := catalog.Validate(ctx, s.catalog, req.Product)
+	var invalid *catalog.ValidationError
+	if errors.As(err, &invalid) {
+		s.logger.Warn("invalid product", telemetry.ZapContext(ctx), zap.Any("product", req.Product), zap.Error(invalid))
+		return nil, invalid
+	}
+	if err != nil {
+		s.logger.Error("failed to validate product", telemetry.ZapContext(ctx), zap.Error(err))
+		return nil, status.Errorf(codes.Unavailable, "validate product: %v", err)
+	}
+
+	// The reserving span is kept with the reservation, so that the span that
+	// confirms it, in another trace, can link back to it.
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	reservation := &redis.Reservation{
+		ID:        random.NewID(),
+		Product:   priced(req.Product, item),
+		ExpiresAt: time.Now().Add(s.config.ReservationTTL),
+		Link:      carrier.Get(traceparentHeader),
+	}
+
+	available, err := s.redisClient.Reserve(ctx, reservation)
+	if errors.Is(err, redis.ErrInsufficientStock) {
+		s.logger.Warn("insufficient stock", telemetry.ZapContext(ctx), zap.Any("product", req.Product), zap.Int64("available", available))
+		return nil, insufficientStockError(req.Product, available)
+	}
+	if err != nil {
+		s.logger.Error("failed to reserve product", telemetry.ZapContext(ctx), zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "reserve product: %v", err)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("reservation.id", reservation.ID))
+	s.recordReservation(ctx, reservation.Product, "reserved")
+
+	s.logger.Info("product reserved", telemetry.ZapContext(ctx), zap.String("reservation_id", reservation.ID), zap.Time("expires_at", reservation.ExpiresAt))
+
+	return &otelworkshop.Reservation{
+		Id:        reservation.ID,
+		Product:   reservation.Product,
+		ExpiresAt: timestamppb.New(reservation.ExpiresAt),
+	}, nil
+}
+
+func (s *RedisShop) ConfirmReservation(ctx context.Context, req *otelworkshop.ConfirmReservationRequest) (*otelworkshop.Product, error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("reservation.id", req.Id))
+
+	reservation, err := s.redisClient.ConfirmReservation(ctx, req.Id, time.Now())
+	if err != nil {
+		return nil, s.reservationError(ctx, req.Id, err)
+	}
+
+	linkCtx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{
+		traceparentHeader: reservation.Link,
+	})
+	if link := trace.SpanContextFromContext(linkCtx); link.IsValid() {
+		span.AddLink(trace.Link{
+			SpanContext: link,
+			Attributes:  []attribute.KeyValue{attribute.String("reservation.id", reservation.ID)},
+		})
+	}
+
+	s.recordSale(ctx, reservation.Product)
+	s.recordPurchases(ctx, reservation.Product)
+	s.recordReservation(ctx, reservation.Product, "confirmed")
+
+	s.logger.Info("reservation confirmed", telemetry.ZapContext(ctx), zap.String("reservation_id", reservation.ID), zap.Any("product", reservation.Product))
+
+	return reservation.Product, nil
+}
+
+func (s *RedisShop) CancelReservation(ctx context.Context, req *otelworkshop.CancelReservationRequest) (*otelworkshop.Empty, error) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("reservation.id", req.Id))
+
+	reservation, err := s.redisClient.CancelReservation(ctx, req.Id)
+	if err != nil {
+		return nil, s.reservationError(ctx, req.Id, err)
+	}
+
+	s.recordReservation(ctx, reservation.Product, "cancelled")
+
+	s.logger.Info("reservation cancelled", telemetry.ZapContext(ctx), zap.String("reservation_id", req.Id), zap.Any("product", reservation.Product))
+
+	return &otelworkshop.Empty{}, nil
+}
+
+func (s *RedisShop) reservationError(ctx context.Context, id string, err error) error {
+	switch {
+	case errors.Is(err, redis.ErrReservationNotFound):
+		s.logger.Warn("reservation not found", telemetry.ZapContext(ctx), zap.String("reservation_id", id))
+		return status.Errorf(codes.NotFound, "reservation %s not found", id)
+	case errors.Is(err, redis.ErrReservationExpired):
+		s.logger.Warn("reservation expired", telemetry.ZapContext(ctx), zap.String("reservation_id", id))
+		return status.Errorf(codes.FailedPrecondition, "reservation %s expired", id)
+	default:
+		s.logger.Error("failed to update reservation", telemetry.ZapContext(ctx), zap.String("reservation_id", id), zap.Error(err))
+		return status.Errorf(codes.Internal, "update reservation: %v", err)
+	}
+}
+
+func (s *RedisShop) recordReservation(ctx context.Context, p *otelworkshop.Product, outcome string) {
+	s.reserved.Add(ctx, 1, metric.WithAttributes(append(product.Attributes(p), attribute.String("reservation.outcome", outcome))...))
+}
+
+// ReleaseExpiredReservations returns every reservation past its expiry to
+// stock.
+func (s *RedisShop) ReleaseExpiredReservations(ctx context.Context) error {
+	for {
+		released, err := s.redisClient.ReleaseExpiredReservations(ctx, time.Now(), releaseBatchSize)
+		for _, reservation := range released {
+			s.recordReservation(ctx, reservation.Product, "expired")
+		}
+		if len(released) > 0 {
+			s.logger.Info("released expired reservations", zap.Int("count", len(released)))
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(released) < releaseBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package shop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestReservationOutcomesCarryProduct(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.Set("hat:red", "10")
+	s := NewRedisShop(zap.NewNop(), mr.Addr(), catalog.NewMemoryCatalog(catalog.Default()...), Config{ReservationTTL: time.Hour})
+	defer s.Close()
+	ctx := context.Background()
+
+	reserve := func() *otelworkshop.Reservation {
+		t.Helper()
+
+		reservation, err := s.ReserveProduct(ctx, &otelworkshop.ReserveProductRequest{
+			Product: &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return reservation
+	}
+
+	if _, err := s.CancelReservation(ctx, &otelworkshop.CancelReservationRequest{Id: reserve().Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.config.ReservationTTL = -time.Minute
+	reserve()
+	if err := s.ReleaseExpiredReservations(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rm := collect(t)
+	outcomes := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "workshop.reservations" {
+				continue
+			}
+			for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				outcome, _ := point.Attributes.Value("reservation.outcome")
+				name, _ := point.Attributes.Value("product.name")
+				outcomes[outcome.AsString()] = name.AsString() == "hat"
+			}
+		}
+	}
+
+	for _, outcome := range []string{"cancelled", "expired"} {
+		if !outcomes[outcome] {
+			t.Errorf("no %q reservation recorded for the hat", outcome)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package shop
+
+import (
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+func NewGRPCServer(shop otelworkshop.ShopServiceServer, health *health.Server, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	otelworkshop.RegisterShopServiceServer(server, shop)
+	healthpb.RegisterHealthServer(server, health)
+	reflection.Register(server)
+
+	return server
+}
@@ -2,77 +2,435 @@ package shop
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strconv"
 	"sync"
+	"time"
+	"vinted/otel-workshop/internal/catalog"
 	"vinted/otel-workshop/internal/product"
 	"vinted/otel-workshop/internal/redis"
+	"vinted/otel-workshop/internal/telemetry"
 	"vinted/otel-workshop/pb/genproto/otelworkshop"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var meter = otel.Meter("vinted/otel-workshop/internal/shop")
+
+const (
+	followBlock = 2 * time.Second
+	followRetry = time.Second
 )
 
 type RedisShop struct {
 	redisClient *redis.WorkshopClient
+	catalog     catalog.Catalog
+	config      Config
 	mux         sync.RWMutex
 	inventory   []*otelworkshop.Product
-	logger      *zap.Logger
+	// position is the last inventory stream entry that inventory reflects.
+	position string
+	watchers watchers
+	logger   *zap.Logger
+	sold     metric.Int64Counter
+	revenue  metric.Int64Counter
+	reserved metric.Int64Counter
+	limited  metric.Int64Counter
+	lag      metric.Float64Histogram
 
 	otelworkshop.UnimplementedShopServiceServer
 }
 
-func NewRedisShop(logger *zap.Logger, redisAddr string) *RedisShop {
-	return &RedisShop{
-		redisClient: redis.NewWorkshopRedisClient(redisAddr),
+type Config struct {
+	// ReservationTTL is how long a reservation holds stock.
+	ReservationTTL time.Duration
+	// CustomerRateLimit caps the buying calls a customer may make per
+	// CustomerRateWindow. Zero disables the limit.
+	CustomerRateLimit  int64
+	CustomerRateWindow time.Duration
+}
+
+// NewRedisShop returns a shop selling the products in cat from the stock in
+// Redis.
+func NewRedisShop(logger *zap.Logger, redisAddr string, cat catalog.Catalog, cfg Config, hooks ...redis.Hook) *RedisShop {
+	s := &RedisShop{
+		redisClient: redis.NewWorkshopRedisClient(redisAddr, hooks...),
+		catalog:     cat,
+		config:      cfg,
 		logger:      logger,
 	}
+
+	var err error
+	s.sold, err = meter.Int64Counter("workshop.products.sold",
+		metric.WithDescription("Number of products sold by the shop."),
+		metric.WithUnit("{product}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	s.revenue, err = meter.Int64Counter("workshop.revenue",
+		metric.WithDescription("Revenue from products sold by the shop, in minor units of the currency."),
+		metric.WithUnit("{minor_unit}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	s.reserved, err = meter.Int64Counter("workshop.reservations",
+		metric.WithDescription("Number of reservations made and ended by the shop, by outcome."),
+		metric.WithUnit("{reservation}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	s.limited, err = meter.Int64Counter("workshop.customers.rate_limited",
+		metric.WithDescription("Number of buying calls rejected because the customer exceeded their rate limit."),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	s.lag, err = meter.Float64Histogram("workshop.inventory.lag",
+		metric.WithDescription("Time from a stock change in Redis until the shop inventory reflects it."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	_, err = meter.Int64ObservableGauge("workshop.products.stock",
+		metric.WithDescription("Product stock as last seen in the shop inventory."),
+		metric.WithUnit("{product}"),
+		metric.WithInt64Callback(s.observeStock),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return s
 }
 
-func (s *RedisShop) ListProducts(ctx context.Context, req *otelworkshop.Empty) (*otelworkshop.ListProductsResponse, error) {
+func (s *RedisShop) Ping(ctx context.Context) error {
+	return s.redisClient.Ping(ctx)
+}
+
+// CloseWatchers ends every WatchInventory stream, which would otherwise
+// keep a graceful stop waiting.
+func (s *RedisShop) CloseWatchers() {
+	s.watchers.closeAll(errShopClosed)
+}
+
+func (s *RedisShop) Close() error {
+	return s.redisClient.Close()
+}
+
+func (s *RedisShop) ListProducts(ctx context.Context, req *otelworkshop.ListProductsRequest) (*otelworkshop.ListProductsResponse, error) {
+	offset, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page token: %v", err)
+	}
+	if req.PageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "negative page size")
+	}
+
 	s.mux.RLock()
-	defer s.mux.RUnlock()
+	var products []*otelworkshop.Product
+	for _, p := range s.inventory {
+		if matches(req.Filter, p) {
+			products = append(products, p)
+		}
+	}
+	s.mux.RUnlock()
+
+	resp := &otelworkshop.ListProductsResponse{}
+	if offset < len(products) {
+		products = products[offset:]
+		if req.PageSize > 0 && int(req.PageSize) < len(products) {
+			products = products[:req.PageSize]
+			resp.NextPageToken = encodePageToken(offset + int(req.PageSize))
+		}
+		resp.Products = products
+	}
 
-	s.logger.Info("listing products", zap.Int("count", len(s.inventory)))
+	s.logger.Info("listing products", telemetry.ZapContext(ctx), zap.Int("count", len(resp.Products)), zap.Any("filter", req.Filter))
 
-	return &otelworkshop.ListProductsResponse{Products: s.inventory}, nil
+	return resp, nil
+}
+
+func (s *RedisShop) WatchInventory(req *otelworkshop.WatchInventoryRequest, stream grpc.ServerStreamingServer[otelworkshop.InventoryChange]) error {
+	ctx := stream.Context()
+
+	watcher := s.watchers.add(req.Filter)
+	defer s.watchers.remove(watcher)
+
+	s.logger.Info("watching inventory", telemetry.ZapContext(ctx), zap.Any("filter", req.Filter))
+
+	for {
+		select {
+		case change, ok := <-watcher.changes:
+			if !ok {
+				s.logger.Info("closing inventory watcher", telemetry.ZapContext(ctx), zap.Error(watcher.err))
+				if errors.Is(watcher.err, errWatcherLagging) {
+					return status.Error(codes.ResourceExhausted, watcher.err.Error())
+				}
+				return status.Error(codes.Unavailable, watcher.err.Error())
+			}
+
+			if err := stream.Send(change); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
 }
 
 func (s *RedisShop) BuyProduct(ctx context.Context, req *otelworkshop.BuyProductRequest) (*otelworkshop.Product, error) {
-	s.logger.Info("buying product", zap.String("name", req.Name), zap.String("surname", req.Surname), zap.Any("product", req.Product))
+	s.logger.Info("buying product", telemetry.ZapContext(ctx), zap.String("name", req.Name), zap.String("surname", req.Surname), zap.Any("product", req.Product))
 
-	err := s.redisClient.Decrement(ctx, req.Product, req.Product.Quantity)
-	if err != nil {
-		s.logger.Error("failed to decrement product quantity", zap.Error(err))
+	if req.Product == nil {
+		return nil, status.Error(codes.InvalidArgument, "product is required")
+	}
+
+	if err := s.limitCustomer(ctx); err != nil {
 		return nil, err
 	}
 
-	s.logger.Info("product bought", zap.String("name", req.Name), zap.String("surname", req.Surname), zap.Any("product", req.Product))
+	item, err := catalog.Validate(ctx, s.catalog, req.Product)
+	var invalid *catalog.ValidationError
+	if errors.As(err, &invalid) {
+		s.logger.Warn("invalid product", telemetry.ZapContext(ctx), zap.Any("product", req.Product), zap.Error(invalid))
+		return nil, invalid
+	}
+	if err != nil {
+		s.logger.Error("failed to validate product", telemetry.ZapContext(ctx), zap.Error(err))
+		return nil, status.Errorf(codes.Unavailable, "validate product: %v", err)
+	}
+
+	available, err := s.redisClient.DecrementIfSufficient(ctx, req.Product, req.Product.Quantity)
+	if errors.Is(err, redis.ErrInsufficientStock) {
+		s.logger.Warn("insufficient stock", telemetry.ZapContext(ctx), zap.Any("product", req.Product), zap.Int64("available", available))
+		return nil, insufficientStockError(req.Product, available)
+	}
+	if err != nil {
+		s.logger.Error("failed to decrement product quantity", telemetry.ZapContext(ctx), zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "decrement product quantity: %v", err)
+	}
+
+	bought := priced(req.Product, item)
+	s.recordSale(ctx, bought)
+	s.recordPurchases(ctx, bought)
+
+	s.logger.Info("product bought", telemetry.ZapContext(ctx), zap.String("name", req.Name), zap.String("surname", req.Surname), zap.Any("product", bought))
+
+	return bought, nil
+}
+
+// priced returns p with the unit price of item.
+func priced(p *otelworkshop.Product, item catalog.Item) *otelworkshop.Product {
+	return &otelworkshop.Product{
+		Name:     p.Name,
+		Color:    p.Color,
+		Quantity: p.Quantity,
+		OrderId:  p.OrderId,
+		Price:    item.Price,
+		Currency: item.Currency,
+	}
+}
+
+func (s *RedisShop) recordSale(ctx context.Context, p *otelworkshop.Product) {
+	attrs := product.Attributes(p)
+	s.sold.Add(ctx, p.Quantity, metric.WithAttributes(attrs...))
+	s.revenue.Add(ctx, p.Quantity*p.Price, metric.WithAttributes(append(attrs, attribute.String("currency", p.Currency))...))
+}
+
+func insufficientStockError(p *otelworkshop.Product, available int64) error {
+	st := status.Newf(codes.FailedPrecondition, "insufficient stock for %s %s: requested %d, available %d", p.Color, p.Name, p.Quantity, available)
 
-	return req.Product, err
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: "INSUFFICIENT_STOCK",
+		Domain: "otelworkshop",
+		Metadata: map[string]string{
+			"name":      p.Name,
+			"color":     p.Color,
+			"requested": strconv.FormatInt(p.Quantity, 10),
+			"available": strconv.FormatInt(available, 10),
+		},
+	})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
 }
 
+// UpdateInventory reloads the whole inventory from Redis. FollowInventory
+// keeps it up to date in between, so this is only a periodic resync.
 func (s *RedisShop) UpdateInventory(ctx context.Context) error {
+	items, err := s.catalog.Items(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The inventory is listed color by color, in the order colors first
+	// appear in the catalog, then by name as the catalog sorts items.
+	// PickPopular weighs products by position, so this decides what sells.
+	var colors []string
+	for _, item := range items {
+		for _, color := range item.Colors {
+			if !slices.Contains(colors, color) {
+				colors = append(colors, color)
+			}
+		}
+	}
+
 	inventory := make([]*otelworkshop.Product, 0)
 
-	for _, color := range product.Colors() {
-		for _, name := range product.Names() {
-			product := &otelworkshop.Product{
-				Name:  name,
-				Color: color,
+	for _, color := range colors {
+		for _, item := range items {
+			if !slices.Contains(item.Colors, color) {
+				continue
 			}
 
-			quantity, err := s.redisClient.GetValue(ctx, product)
-			if err != nil {
-				return err
+			inventory = append(inventory, &otelworkshop.Product{
+				Name:     item.Name,
+				Color:    color,
+				Price:    item.Price,
+				Currency: item.Currency,
+			})
+		}
+	}
+
+	quantities, position, err := s.redisClient.SnapshotValues(ctx, inventory)
+	if err != nil {
+		return err
+	}
+
+	for i, quantity := range quantities {
+		inventory[i].Quantity = quantity
+	}
+
+	s.mux.Lock()
+	// FollowInventory may have applied newer changes while the snapshot was
+	// in flight; installing it would move quantities backwards.
+	if redis.CompareStreamIDs(position, s.position) < 0 {
+		s.mux.Unlock()
+		return nil
+	}
+	previous := s.inventory
+	s.inventory = inventory
+	s.position = position
+	s.mux.Unlock()
+
+	s.publishChanges(previous, inventory)
+
+	return nil
+}
+
+// FollowInventory applies the stock changes published to the Redis inventory
+// stream until ctx is done, starting right after the last UpdateInventory
+// snapshot. Read errors are logged and retried.
+func (s *RedisShop) FollowInventory(ctx context.Context) {
+	s.mux.RLock()
+	after := s.position
+	s.mux.RUnlock()
+	if after == "" {
+		after = "0-0"
+	}
+
+	for ctx.Err() == nil {
+		changes, err := s.redisClient.ReadStockChanges(ctx, after, followBlock)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
 			}
 
-			product.Quantity = quantity
+			s.logger.Error("failed to read stock changes", zap.Error(err))
+
+			select {
+			case <-time.After(followRetry):
+			case <-ctx.Done():
+			}
+			continue
+		}
 
-			inventory = append(inventory, product)
+		if len(changes) == 0 {
+			continue
 		}
+
+		after = changes[len(changes)-1].ID
+		s.applyChanges(ctx, changes)
 	}
+}
 
+func (s *RedisShop) applyChanges(ctx context.Context, changes []redis.StockChange) {
 	s.mux.Lock()
+	previous := s.inventory
+	inventory := slices.Clone(previous)
+	for _, change := range changes {
+		// Changes up to position are already in the snapshot.
+		if redis.CompareStreamIDs(change.ID, s.position) <= 0 {
+			continue
+		}
+		s.position = change.ID
+		s.lag.Record(ctx, time.Since(change.Time).Seconds(), metric.WithAttributes(product.Attributes(change.Product)...))
+
+		i := slices.IndexFunc(inventory, func(p *otelworkshop.Product) bool {
+			return p.Name == change.Product.Name && p.Color == change.Product.Color
+		})
+		if i >= 0 {
+			inventory[i] = &otelworkshop.Product{
+				Name:     change.Product.Name,
+				Color:    change.Product.Color,
+				Quantity: change.Product.Quantity,
+				Price:    inventory[i].Price,
+				Currency: inventory[i].Currency,
+			}
+		}
+	}
 	s.inventory = inventory
 	s.mux.Unlock()
 
+	s.publishChanges(previous, inventory)
+}
+
+func (s *RedisShop) publishChanges(previous, current []*otelworkshop.Product) {
+	quantities := make(map[string]int64, len(previous))
+	for _, p := range previous {
+		quantities[p.Name+":"+p.Color] = p.Quantity
+	}
+
+	for _, p := range current {
+		quantity, ok := quantities[p.Name+":"+p.Color]
+		if ok && quantity == p.Quantity {
+			continue
+		}
+
+		s.watchers.publish(&otelworkshop.InventoryChange{
+			Product:          p,
+			PreviousQuantity: quantity,
+		})
+	}
+}
+
+func (s *RedisShop) observeStock(_ context.Context, o metric.Int64Observer) error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, p := range s.inventory {
+		o.Observe(p.Quantity, metric.WithAttributes(product.Attributes(p)...))
+	}
+
 	return nil
 }
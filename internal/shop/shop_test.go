@@ -0,0 +1,182 @@
+package shop
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"vinted/otel-workshop/internal/catalog"
+	"vinted/otel-workshop/internal/product"
+	"vinted/otel-workshop/internal/redis"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+var redHat = &otelworkshop.Product{Name: "hat", Color: "red"}
+
+// reader collects the metrics of every test: the package meter delegates to
+// the first global provider only, so it can't be swapped per test.
+var reader = sdkmetric.NewManualReader()
+
+func TestMain(m *testing.M) {
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	os.Exit(m.Run())
+}
+
+func collect(t *testing.T) metricdata.ResourceMetrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	return rm
+}
+
+// lagCount returns how many lag samples were recorded for red hats.
+func lagCount(t *testing.T) uint64 {
+	t.Helper()
+
+	var count uint64
+	for _, sm := range collect(t).ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "workshop.inventory.lag" {
+				continue
+			}
+			for _, point := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+				if name, _ := point.Attributes.Value("product.name"); name.AsString() == redHat.Name {
+					count += point.Count
+				}
+			}
+		}
+	}
+
+	return count
+}
+
+func newTestShop(t *testing.T) *RedisShop {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	s := NewRedisShop(zap.NewNop(), mr.Addr(), catalog.NewMemoryCatalog(catalog.Default()...), Config{})
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func (s *RedisShop) quantity(p *otelworkshop.Product) int64 {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, item := range s.inventory {
+		if item.Name == p.Name && item.Color == p.Color {
+			return item.Quantity
+		}
+	}
+
+	return -1
+}
+
+func TestFollowInventoryStartsAtSnapshot(t *testing.T) {
+	s := newTestShop(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := s.redisClient.Increment(ctx, redHat, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateInventory(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A change made before the follower starts reading must not be lost.
+	if _, err := s.redisClient.DecrementMany(ctx, []*otelworkshop.Product{{Name: "hat", Color: "red", Quantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.FollowInventory(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.quantity(redHat) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("quantity = %d, want 3", s.quantity(redHat))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestInventoryNeverMovesBackwards(t *testing.T) {
+	s := newTestShop(t)
+	ctx := context.Background()
+
+	if err := s.redisClient.Increment(ctx, redHat, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateInventory(ctx); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := s.position
+	lag := lagCount(t)
+
+	// A change already in the snapshot is skipped.
+	s.applyChanges(ctx, []redis.StockChange{{
+		ID:      snapshot,
+		Product: &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1},
+		Time:    time.Now(),
+	}})
+	if got := s.quantity(redHat); got != 5 {
+		t.Errorf("quantity = %d after a change already in the snapshot, want 5", got)
+	}
+	if got := lagCount(t); got != lag {
+		t.Errorf("lag recorded %d times for a skipped change, want %d", got, lag)
+	}
+
+	// A snapshot older than the applied changes is not installed.
+	s.applyChanges(ctx, []redis.StockChange{{
+		ID:      "99999999999999-0",
+		Product: &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 9},
+		Time:    time.Now(),
+	}})
+	if err := s.UpdateInventory(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.quantity(redHat); got != 9 {
+		t.Errorf("quantity = %d after a stale resync, want 9", got)
+	}
+	if got := lagCount(t); got != lag+1 {
+		t.Errorf("lag recorded %d times, want %d", got, lag+1)
+	}
+}
+
+func TestUpdateInventoryListsColorByColor(t *testing.T) {
+	s := newTestShop(t)
+
+	if err := s.UpdateInventory(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	names, colors := slices.Sorted(slices.Values(product.Names())), product.Colors()
+	if len(s.inventory) != len(names)*len(colors) {
+		t.Fatalf("got %d products, want %d", len(s.inventory), len(names)*len(colors))
+	}
+	for i, p := range s.inventory {
+		if want := colors[i/len(names)] + " " + names[i%len(names)]; p.Color+" "+p.Name != want {
+			t.Errorf("product %d = %s %s, want %s", i, p.Color, p.Name, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// LateStepTimeout bounds each step that is reached after the Run context
+// expired, so that a slow drain doesn't also cost the closers and the
+// telemetry flush behind it.
+const LateStepTimeout = 2 * time.Second
+
+// NotifyContext returns a copy of parent that is cancelled on SIGINT or
+// SIGTERM, which is what starts the shutdown of every service.
+func NotifyContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
+
+type step struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// Sequence runs shutdown steps in the order they were added, so servers
+// are drained before the producers, clients and telemetry they depend on.
+type Sequence struct {
+	mux   sync.Mutex
+	steps []step
+}
+
+func (s *Sequence) Add(name string, fn func(context.Context) error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.steps = append(s.steps, step{name: name, fn: fn})
+}
+
+func (s *Sequence) AddCloser(name string, closer io.Closer) {
+	s.Add(name, func(context.Context) error {
+		return closer.Close()
+	})
+}
+
+// Run executes the steps in order and returns their joined errors. A step
+// still running when ctx expires is abandoned, and every step after it runs
+// with its own LateStepTimeout.
+func (s *Sequence) Run(ctx context.Context) error {
+	s.mux.Lock()
+	steps := s.steps
+	s.steps = nil
+	s.mux.Unlock()
+
+	var errs []error
+	for _, step := range steps {
+		if err := runStep(ctx, step.fn); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func runStep(ctx context.Context, fn func(context.Context) error) error {
+	if ctx.Err() == nil {
+		return run(ctx, fn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), LateStepTimeout)
+	defer cancel()
+
+	return run(ctx, fn)
+}
+
+func run(ctx context.Context, fn func(context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,97 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRunCompletesInFlightRequests(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})}
+	go server.Serve(listener)
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+	<-started
+
+	var closed bool
+	var steps Sequence
+	steps.Add("http server", server.Shutdown)
+	steps.Add("telemetry", func(context.Context) error {
+		closed = true
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := steps.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-responses
+	if got.err != nil || got.body != "done" {
+		t.Errorf("in-flight request got %q, %v; want %q", got.body, got.err, "done")
+	}
+	if !closed {
+		t.Error("telemetry step did not run")
+	}
+}
+
+func TestRunContinuesAfterDeadline(t *testing.T) {
+	var steps Sequence
+	steps.Add("slow drain", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	var flushCtxErr error
+	flushed := false
+	steps.Add("telemetry", func(ctx context.Context) error {
+		flushed = true
+		flushCtxErr = ctx.Err()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := steps.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the drain to exceed its deadline", err)
+	}
+	if !flushed {
+		t.Fatal("telemetry step was skipped after the deadline")
+	}
+	if flushCtxErr != nil {
+		t.Errorf("telemetry step got an expired context: %v", flushCtxErr)
+	}
+}
@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"context"
+
+	"vinted/otel-workshop/internal/customer"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// baggageSpanProcessor copies the customer.id baggage member onto the spans
+// started in its context, so that spans can be filtered by customer in
+// services that never look at the baggage themselves. Other members are
+// left out: baggage comes from callers, and copying all of it would let
+// them set arbitrary attributes.
+type baggageSpanProcessor struct{}
+
+var _ sdktrace.SpanProcessor = baggageSpanProcessor{}
+
+func (baggageSpanProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	if member := baggage.FromContext(ctx).Member(customer.BaggageKey); member.Key() != "" {
+		span.SetAttributes(attribute.String(member.Key(), member.Value()))
+	}
+}
+
+func (baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (baggageSpanProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (baggageSpanProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// baggageLogProcessor does the same for log records. It must be registered
+// before the processors that export them.
+type baggageLogProcessor struct{}
+
+var _ sdklog.Processor = baggageLogProcessor{}
+
+func (baggageLogProcessor) OnEmit(ctx context.Context, record *sdklog.Record) error {
+	if member := baggage.FromContext(ctx).Member(customer.BaggageKey); member.Key() != "" {
+		record.AddAttributes(log.String(member.Key(), member.Value()))
+	}
+
+	return nil
+}
+
+func (baggageLogProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (baggageLogProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"vinted/otel-workshop/internal/customer"
+
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBaggageSpanProcessorCopiesOnlyCustomerID(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(baggageSpanProcessor{}),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+
+	ctx, err := customer.ContextWithID(context.Background(), "c0123456789ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := baggage.NewMember("http.route", "/admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+
+	_, span := provider.Tracer("test").Start(ctx, "test")
+	span.End()
+
+	attributes := recorder.Ended()[0].Attributes()
+	if len(attributes) != 1 || string(attributes[0].Key) != customer.BaggageKey || attributes[0].Value.AsString() != "c0123456789ab" {
+		t.Errorf("attributes = %v, want only %s", attributes, customer.BaggageKey)
+	}
+}
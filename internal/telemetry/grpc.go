@@ -0,0 +1,29 @@
+package telemetry
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"google.golang.org/grpc"
+)
+
+// GRPCServerOptions instruments a gRPC server with rpc.* spans and the
+// rpc.server.duration histogram, extracting context from incoming metadata.
+// Health checks are not traced.
+func GRPCServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler(
+			otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+		)),
+	}
+}
+
+// GRPCDialOptions instruments a gRPC client with rpc.* spans and the
+// rpc.client.duration histogram, injecting context into outgoing metadata.
+// Health checks are not traced.
+func GRPCDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+			otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+		)),
+	}
+}
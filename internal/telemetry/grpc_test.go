@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testShop struct {
+	otelworkshop.UnimplementedShopServiceServer
+}
+
+func (testShop) ListProducts(context.Context, *otelworkshop.ListProductsRequest) (*otelworkshop.ListProductsResponse, error) {
+	return &otelworkshop.ListProductsResponse{}, nil
+}
+
+func TestGRPCInstrumentation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(GRPCServerOptions()...)
+	otelworkshop.RegisterShopServiceServer(server, testShop{})
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", append(GRPCDialOptions(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := otelworkshop.NewShopServiceClient(conn).ListProducts(ctx, &otelworkshop.ListProductsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Close()
+	server.GracefulStop()
+
+	spans := make(map[trace.SpanKind]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.Name() != otelworkshop.ShopService_ListProducts_FullMethodName[1:] {
+			t.Errorf("unexpected span %s", span.Name())
+			continue
+		}
+		spans[span.SpanKind()] = span
+	}
+
+	clientSpan, serverSpan := spans[trace.SpanKindClient], spans[trace.SpanKindServer]
+	if clientSpan == nil || serverSpan == nil {
+		t.Fatalf("spans = %v, want a client and a server span", recorder.Ended())
+	}
+	if serverSpan.SpanContext().TraceID() != clientSpan.SpanContext().TraceID() {
+		t.Error("client and server spans are in different traces")
+	}
+	if serverSpan.Parent().SpanID() != clientSpan.SpanContext().SpanID() {
+		t.Error("the server span is not a child of the client span")
+	}
+}
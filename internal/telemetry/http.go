@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"vinted/otel-workshop/internal/health"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HTTPHandler instruments mux with http.server.* spans and metrics. Spans are
+// named "{method} {route}" after the mux pattern that matches the request.
+// Liveness and readiness probes are not instrumented. Middlewares wrap mux
+// inside the instrumentation, so they see the server span.
+func HTTPHandler(mux *http.ServeMux, middlewares ...func(http.Handler) http.Handler) http.Handler {
+	route := func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+
+	var next http.Handler = mux
+	for _, middleware := range middlewares {
+		next = middleware(next)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pattern := route(r); pattern != "" {
+			trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(pattern))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	return otelhttp.NewHandler(handler, "",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != health.LivenessPath && r.URL.Path != health.ReadinessPath
+		}),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if pattern := route(r); pattern != "" {
+				return r.Method + " " + pattern
+			}
+			return r.Method
+		}),
+		otelhttp.WithMetricAttributesFn(func(r *http.Request) []attribute.KeyValue {
+			if pattern := route(r); pattern != "" {
+				return []attribute.KeyValue{semconv.HTTPRoute(pattern)}
+			}
+			return nil
+		}),
+	)
+}
+
+// HTTPTransport instruments base with http.client.* spans and metrics and
+// injects the trace context into outgoing request headers.
+func HTTPTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
@@ -0,0 +1,79 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/bridges/otellogrus"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/contrib/bridges/otelzap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SlogHandler tees records written to handler into the OTel logs pipeline.
+// Use the *Context logging methods so records carry the active span.
+func SlogHandler(name string, handler slog.Handler) slog.Handler {
+	return fanoutHandler{handler, otelslog.NewHandler(name)}
+}
+
+// LogrusHook emits logrus entries to the OTel logs pipeline. Use
+// logger.WithContext so entries carry the active span.
+func LogrusHook(name string) logrus.Hook {
+	return otellogrus.NewHook(name)
+}
+
+// ZapCore tees entries written to core into the OTel logs pipeline. Pass
+// ZapContext as a field so entries carry the active span.
+func ZapCore(name string, core zapcore.Core) zapcore.Core {
+	return zapcore.NewTee(core, otelzap.NewCore(name))
+}
+
+// ZapContext returns a field that hands ctx to the OTel core without being
+// written by the other encoders.
+func ZapContext(ctx context.Context) zap.Field {
+	return zap.Field{Key: "context", Type: zapcore.SkipType, Interface: ctx}
+}
+
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var err error
+	for _, handler := range h {
+		if handler.Enabled(ctx, record.Level) {
+			err = errors.Join(err, handler.Handle(ctx, record.Clone()))
+		}
+	}
+
+	return err
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, 0, len(h))
+	for _, handler := range h {
+		handlers = append(handlers, handler.WithAttrs(attrs))
+	}
+
+	return handlers
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, 0, len(h))
+	for _, handler := range h {
+		handlers = append(handlers, handler.WithGroup(name))
+	}
+
+	return handlers
+}
@@ -0,0 +1,169 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+type ShutdownFunc func(context.Context) error
+
+type options struct {
+	spanExporter sdktrace.SpanExporter
+	metricReader sdkmetric.Reader
+	logExporter  sdklog.Exporter
+}
+
+type Option func(*options)
+
+// WithSpanExporter replaces the OTLP span exporter. Spans are exported
+// synchronously so that in-memory exporters see them as soon as they end.
+func WithSpanExporter(exporter sdktrace.SpanExporter) Option {
+	return func(o *options) {
+		o.spanExporter = exporter
+	}
+}
+
+// WithMetricReader replaces the periodic OTLP metric reader, e.g. with a
+// sdkmetric.ManualReader.
+func WithMetricReader(reader sdkmetric.Reader) Option {
+	return func(o *options) {
+		o.metricReader = reader
+	}
+}
+
+// WithLogExporter replaces the OTLP log exporter. Records are exported
+// synchronously, same as spans.
+func WithLogExporter(exporter sdklog.Exporter) Option {
+	return func(o *options) {
+		o.logExporter = exporter
+	}
+}
+
+// Setup configures global tracer, meter and logger providers from the
+// standard OTEL_* environment variables and installs W3C trace context and
+// baggage propagators. The returned func flushes and shuts all of them down.
+func Setup(ctx context.Context, serviceName string, opts ...Option) (ShutdownFunc, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var shutdownFuncs []ShutdownFunc
+	shutdown := func(ctx context.Context) error {
+		var err error
+		for _, fn := range shutdownFuncs {
+			err = errors.Join(err, fn(ctx))
+		}
+		shutdownFuncs = nil
+		return err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	tracerProvider, err := newTracerProvider(ctx, res, o.spanExporter)
+	if err != nil {
+		return nil, errors.Join(err, shutdown(ctx))
+	}
+	shutdownFuncs = append(shutdownFuncs, tracerProvider.Shutdown)
+	otel.SetTracerProvider(tracerProvider)
+
+	meterProvider, err := newMeterProvider(ctx, res, o.metricReader)
+	if err != nil {
+		return nil, errors.Join(err, shutdown(ctx))
+	}
+	shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
+	otel.SetMeterProvider(meterProvider)
+
+	loggerProvider, err := newLoggerProvider(ctx, res, o.logExporter)
+	if err != nil {
+		return nil, errors.Join(err, shutdown(ctx))
+	}
+	shutdownFuncs = append(shutdownFuncs, loggerProvider.Shutdown)
+	global.SetLoggerProvider(loggerProvider)
+
+	return shutdown, nil
+}
+
+func newTracerProvider(ctx context.Context, res *resource.Resource, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	if exporter != nil {
+		return sdktrace.NewTracerProvider(
+			sdktrace.WithResource(res),
+			sdktrace.WithSpanProcessor(baggageSpanProcessor{}),
+			sdktrace.WithSyncer(exporter),
+		), nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(baggageSpanProcessor{}),
+		sdktrace.WithBatcher(exporter),
+	), nil
+}
+
+func newMeterProvider(ctx context.Context, res *resource.Resource, reader sdkmetric.Reader) (*sdkmetric.MeterProvider, error) {
+	if reader == nil {
+		exporter, err := otlpmetricgrpc.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		reader = sdkmetric.NewPeriodicReader(exporter)
+	}
+
+	return sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(reader),
+	), nil
+}
+
+func newLoggerProvider(ctx context.Context, res *resource.Resource, exporter sdklog.Exporter) (*sdklog.LoggerProvider, error) {
+	if exporter != nil {
+		return sdklog.NewLoggerProvider(
+			sdklog.WithResource(res),
+			sdklog.WithProcessor(baggageLogProcessor{}),
+			sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)),
+		), nil
+	}
+
+	exporter, err := otlploggrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(baggageLogProcessor{}),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+	), nil
+}
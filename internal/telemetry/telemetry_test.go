@@ -0,0 +1,171 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+// logRecorder is an in-memory log exporter.
+type logRecorder struct {
+	mux      sync.Mutex
+	records  []sdklog.Record
+	shutdown bool
+}
+
+func (r *logRecorder) Export(_ context.Context, records []sdklog.Record) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for _, record := range records {
+		r.records = append(r.records, record.Clone())
+	}
+
+	return nil
+}
+
+func (r *logRecorder) Shutdown(context.Context) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.shutdown = true
+
+	return nil
+}
+
+func (r *logRecorder) ForceFlush(context.Context) error {
+	return nil
+}
+
+func serviceName(res *resource.Resource) string {
+	name, _ := res.Set().Value(semconv.ServiceNameKey)
+	return name.AsString()
+}
+
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+	spans := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	logs := &logRecorder{}
+
+	shutdown, err := Setup(ctx, "workshop-test",
+		WithSpanExporter(spans),
+		WithMetricReader(reader),
+		WithLogExporter(logs),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := otel.Tracer("test").Start(ctx, "operation")
+	counter, err := otel.Meter("test").Int64Counter("test.operations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 1)
+	slog.New(SlogHandler("test", slog.NewTextHandler(io.Discard, nil))).InfoContext(ctx, "operation done")
+	span.End()
+
+	if got := spans.GetSpans(); len(got) != 1 || got[0].Name != "operation" || serviceName(got[0].Resource) != "workshop-test" {
+		t.Errorf("spans = %v, want the operation span of workshop-test", got)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	if serviceName(rm.Resource) != "workshop-test" || len(rm.ScopeMetrics) != 1 || rm.ScopeMetrics[0].Metrics[0].Name != "test.operations" {
+		t.Errorf("metrics = %+v, want test.operations of workshop-test", rm)
+	}
+
+	logs.mux.Lock()
+	if len(logs.records) != 1 || logs.records[0].Body().AsString() != "operation done" || serviceName(logs.records[0].Resource()) != "workshop-test" {
+		t.Errorf("logs = %v, want the operation log of workshop-test", logs.records)
+	}
+	if record := logs.records[0]; record.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("log trace ID = %s, want %s", record.TraceID(), span.SpanContext().TraceID())
+	}
+	logs.mux.Unlock()
+
+	if err := shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !logs.shutdown {
+		t.Error("shutdown did not shut the log exporter down")
+	}
+	if err := reader.Collect(ctx, &rm); !errors.Is(err, sdkmetric.ErrReaderShutdown) {
+		t.Errorf("collect after shutdown: err = %v, want ErrReaderShutdown", err)
+	}
+}
+
+// metricRecorder is an in-memory metric exporter that counts data points.
+type metricRecorder struct {
+	mux    sync.Mutex
+	points int
+}
+
+func (r *metricRecorder) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (r *metricRecorder) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (r *metricRecorder) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for _, sm := range rm.ScopeMetrics {
+		r.points += len(sm.Metrics)
+	}
+
+	return nil
+}
+
+func (r *metricRecorder) ForceFlush(context.Context) error { return nil }
+func (r *metricRecorder) Shutdown(context.Context) error   { return nil }
+
+func TestSetupShutdownFlushes(t *testing.T) {
+	ctx := context.Background()
+	exporter := &metricRecorder{}
+
+	// The reader would only export in an hour, so anything exported comes
+	// from the shutdown.
+	shutdown, err := Setup(ctx, "workshop-test",
+		WithSpanExporter(tracetest.NewInMemoryExporter()),
+		WithMetricReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(time.Hour))),
+		WithLogExporter(&logRecorder{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter, err := otel.Meter("test").Int64Counter("test.operations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 1)
+
+	if err := shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	exporter.mux.Lock()
+	defer exporter.mux.Unlock()
+	if exporter.points != 1 {
+		t.Errorf("exported %d metrics on shutdown, want 1", exporter.points)
+	}
+}
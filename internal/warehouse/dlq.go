@@ -0,0 +1,296 @@
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vinted/otel-workshop/internal/kafka"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	HeaderDeadLetterError     = "x-dlq-error"
+	HeaderDeadLetterTopic     = "x-dlq-topic"
+	HeaderDeadLetterPartition = "x-dlq-partition"
+	HeaderDeadLetterOffset    = "x-dlq-offset"
+	HeaderDeadLetterTimestamp = "x-dlq-timestamp"
+)
+
+type RetryPolicy struct {
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.Backoff << attempt
+	if p.MaxBackoff > 0 && (backoff > p.MaxBackoff || backoff < p.Backoff) {
+		return p.MaxBackoff
+	}
+
+	return backoff
+}
+
+type DeadLetterer interface {
+	DeadLetter(ctx context.Context, message *sarama.ConsumerMessage, cause error) error
+}
+
+type KafkaDeadLetterer struct {
+	topic    string
+	producer sarama.SyncProducer
+	logger   *slog.Logger
+}
+
+func NewKafkaDeadLetterer(logger *slog.Logger, brokerAddresses []string, topic string) (*KafkaDeadLetterer, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(brokerAddresses, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KafkaDeadLetterer{
+		topic:    topic,
+		producer: producer,
+		logger:   logger,
+	}, nil
+}
+
+// DeadLetter publishes message to the dead-letter topic with its original
+// key, value and headers, plus headers describing where it came from and why
+// it failed.
+func (d *KafkaDeadLetterer) DeadLetter(ctx context.Context, message *sarama.ConsumerMessage, cause error) error {
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers)+5)
+	for _, h := range message.Headers {
+		if h != nil && !isDeadLetterHeader(string(h.Key)) {
+			headers = append(headers, *h)
+		}
+	}
+
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterError), Value: []byte(cause.Error())},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterTopic), Value: []byte(message.Topic)},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterPartition), Value: []byte(strconv.Itoa(int(message.Partition)))},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterOffset), Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterTimestamp), Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	_, _, err := d.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   d.topic,
+		Key:     sarama.ByteEncoder(message.Key),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.logger.WarnContext(ctx, "dead-lettered message", "topic", message.Topic, "partition", message.Partition, "offset", message.Offset, "error", cause)
+
+	return nil
+}
+
+func (d *KafkaDeadLetterer) Close() error {
+	return d.producer.Close()
+}
+
+func isDeadLetterHeader(key string) bool {
+	return strings.HasPrefix(key, "x-dlq-")
+}
+
+// Replayer moves records from a dead-letter topic back to the topic they
+// were dead-lettered from.
+type Replayer struct {
+	consumerGroup sarama.ConsumerGroup
+	producer      sarama.SyncProducer
+	topic         string
+	idleTimeout   time.Duration
+	logger        *slog.Logger
+}
+
+func NewReplayer(logger *slog.Logger, brokerAddresses []string, topic, groupID string, idleTimeout time.Duration) (*Replayer, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	saramaConfig.Producer.Return.Successes = true
+
+	consumerGroup, err := sarama.NewConsumerGroup(brokerAddresses, groupID, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducer(brokerAddresses, saramaConfig)
+	if err != nil {
+		return nil, errors.Join(err, consumerGroup.Close())
+	}
+
+	return &Replayer{
+		consumerGroup: consumerGroup,
+		producer:      producer,
+		topic:         topic,
+		idleTimeout:   idleTimeout,
+		logger:        logger,
+	}, nil
+}
+
+// Replay republishes dead-lettered records until none have arrived for the
+// idle timeout, and returns the number of records moved.
+func (r *Replayer) Replay(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	handler := &replayHandler{
+		producer: r.producer,
+		activity: make(chan struct{}, 1),
+		logger:   r.logger,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		for ctx.Err() == nil {
+			if err := r.consumerGroup.Consume(ctx, []string{r.topic}, handler); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	timer := time.NewTimer(r.idleTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-handler.activity:
+			timer.Reset(r.idleTimeout)
+		case <-timer.C:
+			cancel()
+			<-errs
+			return handler.replayed(), nil
+		case err := <-errs:
+			return handler.replayed(), err
+		case <-ctx.Done():
+			return handler.replayed(), ctx.Err()
+		}
+	}
+}
+
+func (r *Replayer) Close() error {
+	return errors.Join(r.consumerGroup.Close(), r.producer.Close())
+}
+
+type replayHandler struct {
+	producer sarama.SyncProducer
+	activity chan struct{}
+	logger   *slog.Logger
+
+	mux   sync.Mutex
+	count int
+}
+
+func (h *replayHandler) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *replayHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *replayHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			if err := h.replay(session.Context(), message); err != nil {
+				return err
+			}
+
+			session.MarkMessage(message, "")
+
+			select {
+			case h.activity <- struct{}{}:
+			default:
+			}
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (h *replayHandler) replay(ctx context.Context, message *sarama.ConsumerMessage) error {
+	topic := ""
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers))
+	for _, header := range message.Headers {
+		if header == nil {
+			continue
+		}
+
+		key := string(header.Key)
+		if key == HeaderDeadLetterTopic {
+			topic = string(header.Value)
+		}
+		if !isDeadLetterHeader(key) {
+			headers = append(headers, *header)
+		}
+	}
+
+	if topic == "" {
+		h.logger.WarnContext(ctx, "skipping dead-lettered message without source topic", "offset", message.Offset)
+		return nil
+	}
+
+	ctx = kafka.Extract(ctx, message)
+	ctx, span := tracer.Start(ctx, message.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(message.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(int(message.Partition))),
+			semconv.MessagingKafkaOffset(int(message.Offset)),
+		),
+	)
+	defer span.End()
+
+	replayed := &sarama.ProducerMessage{
+		Topic:   topic,
+		Key:     sarama.ByteEncoder(message.Key),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	}
+	kafka.Inject(ctx, replayed)
+
+	_, _, err := h.producer.SendMessage(replayed)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	h.mux.Lock()
+	h.count++
+	h.mux.Unlock()
+
+	h.logger.InfoContext(ctx, "replayed message", "topic", topic, "offset", message.Offset)
+
+	return nil
+}
+
+func (h *replayHandler) replayed() int {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	return h.count
+}
@@ -0,0 +1,284 @@
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"vinted/otel-workshop/internal/chaos"
+	"vinted/otel-workshop/internal/kafka"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+var errStorageDown = errors.New("storage down")
+
+// flakyStorage fails the first failures calls to Store.
+type flakyStorage struct {
+	failures int
+
+	mux      sync.Mutex
+	attempts int
+	stored   []*otelworkshop.Product
+}
+
+func (s *flakyStorage) Store(_ context.Context, _ string, p *otelworkshop.Product) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errStorageDown
+	}
+	s.stored = append(s.stored, p)
+
+	return nil
+}
+
+func (s *flakyStorage) StoreMany(ctx context.Context, deliveries []Delivery) error {
+	for _, d := range deliveries {
+		if err := s.Store(ctx, d.MessageID, d.Product); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type recordingDeadLetterer struct {
+	mux      sync.Mutex
+	messages []*sarama.ConsumerMessage
+	causes   []error
+}
+
+func (d *recordingDeadLetterer) DeadLetter(_ context.Context, message *sarama.ConsumerMessage, cause error) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.messages = append(d.messages, message)
+	d.causes = append(d.causes, cause)
+
+	return nil
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+	for attempt, want := range map[int]time.Duration{
+		0:  10 * time.Millisecond,
+		1:  20 * time.Millisecond,
+		2:  40 * time.Millisecond,
+		3:  50 * time.Millisecond,
+		62: 50 * time.Millisecond,
+	} {
+		if got := policy.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestStoreRetriesBeforeDeadLettering(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		value        []byte
+		wantAttempts int
+		wantStored   int
+		wantDead     error
+	}{
+		{name: "recovers within retries", failures: 2, wantAttempts: 3, wantStored: 1},
+		{name: "exhausts retries", failures: 5, wantAttempts: 3, wantDead: errStorageDown},
+		{name: "malformed is not retried", value: []byte("{"), wantDead: ErrMalformedProduct},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &flakyStorage{failures: tt.failures}
+			deadLetters := &recordingDeadLetterer{}
+			handler := &productHandler{
+				storage:     storage,
+				groupID:     "warehouse",
+				batchSize:   1,
+				retry:       RetryPolicy{Retries: 2, Backoff: time.Millisecond},
+				deadLetters: deadLetters,
+				chaos:       chaos.New(random.New(1)),
+				logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+
+			message := productMessage(t, "m1", &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1})
+			if tt.value != nil {
+				message.Value = tt.value
+			}
+
+			if marked := consumePartition(t, handler, []*sarama.ConsumerMessage{message}); len(marked) != 1 {
+				t.Fatalf("marked %d messages, want 1", len(marked))
+			}
+
+			if storage.attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", storage.attempts, tt.wantAttempts)
+			}
+			if len(storage.stored) != tt.wantStored {
+				t.Errorf("stored = %d, want %d", len(storage.stored), tt.wantStored)
+			}
+
+			if tt.wantDead == nil {
+				if len(deadLetters.messages) != 0 {
+					t.Errorf("dead-lettered %d messages, want none", len(deadLetters.messages))
+				}
+				return
+			}
+			if len(deadLetters.messages) != 1 {
+				t.Fatalf("dead-lettered %d messages, want 1", len(deadLetters.messages))
+			}
+			if !errors.Is(deadLetters.causes[0], tt.wantDead) {
+				t.Errorf("cause = %v, want %v", deadLetters.causes[0], tt.wantDead)
+			}
+		})
+	}
+}
+
+func headerMap(headers []sarama.RecordHeader) map[string]string {
+	values := make(map[string]string, len(headers))
+	for _, h := range headers {
+		values[string(h.Key)] = string(h.Value)
+	}
+
+	return values
+}
+
+func TestKafkaDeadLettererKeepsHeaders(t *testing.T) {
+	var sent *sarama.ProducerMessage
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	d := &KafkaDeadLetterer{
+		topic:    "items-dlq",
+		producer: producer,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	defer d.Close()
+
+	message := productMessage(t, "m1", &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1})
+	message.Key = []byte("hat")
+	message.Partition = 3
+	message.Offset = 42
+	// A message dead-lettered before is described afresh.
+	message.Headers = append(message.Headers, &sarama.RecordHeader{Key: []byte(HeaderDeadLetterError), Value: []byte("stale")})
+
+	if err := d.DeadLetter(context.Background(), message, errStorageDown); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent.Topic != "items-dlq" {
+		t.Errorf("topic = %s, want items-dlq", sent.Topic)
+	}
+	if key, _ := sent.Key.Encode(); string(key) != "hat" {
+		t.Errorf("key = %s, want hat", key)
+	}
+	if value, _ := sent.Value.Encode(); string(value) != string(message.Value) {
+		t.Errorf("value = %s, want %s", value, message.Value)
+	}
+
+	headers := headerMap(sent.Headers)
+	for key, want := range map[string]string{
+		kafka.HeaderMessageID:     "m1",
+		HeaderDeadLetterError:     errStorageDown.Error(),
+		HeaderDeadLetterTopic:     "items",
+		HeaderDeadLetterPartition: "3",
+		HeaderDeadLetterOffset:    "42",
+	} {
+		if headers[key] != want {
+			t.Errorf("header %s = %q, want %q", key, headers[key], want)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, headers[HeaderDeadLetterTimestamp]); err != nil {
+		t.Errorf("header %s: %v", HeaderDeadLetterTimestamp, err)
+	}
+	if len(sent.Headers) != len(message.Headers)-1+5 {
+		t.Errorf("got %d headers, want the %d original ones and 5 dlq ones", len(sent.Headers), len(message.Headers)-1)
+	}
+}
+
+// testConsumerGroup hands messages to the first Consume call and then
+// waits, like a group with nothing left to deliver.
+type testConsumerGroup struct {
+	t        *testing.T
+	messages []*sarama.ConsumerMessage
+	consumed bool
+}
+
+func (g *testConsumerGroup) Consume(ctx context.Context, _ []string, handler sarama.ConsumerGroupHandler) error {
+	if g.consumed {
+		<-ctx.Done()
+		return nil
+	}
+	g.consumed = true
+
+	return handler.ConsumeClaim(&testSession{ctx: ctx}, newTestClaim(g.t, g.messages))
+}
+
+func (g *testConsumerGroup) Errors() <-chan error      { return nil }
+func (g *testConsumerGroup) Close() error              { return nil }
+func (g *testConsumerGroup) Pause(map[string][]int32)  {}
+func (g *testConsumerGroup) Resume(map[string][]int32) {}
+func (g *testConsumerGroup) PauseAll()                 {}
+func (g *testConsumerGroup) ResumeAll()                {}
+
+func TestReplayerRepublishesToSourceTopic(t *testing.T) {
+	dead := productMessage(t, "m1", &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1})
+	dead.Topic = "items-dlq"
+	dead.Headers = append(dead.Headers,
+		&sarama.RecordHeader{Key: []byte(HeaderDeadLetterTopic), Value: []byte("items")},
+		&sarama.RecordHeader{Key: []byte(HeaderDeadLetterError), Value: []byte(errStorageDown.Error())},
+	)
+	orphan := productMessage(t, "m2", &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1})
+	orphan.Topic = "items-dlq"
+
+	var sent *sarama.ProducerMessage
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	replayer := &Replayer{
+		consumerGroup: &testConsumerGroup{t: t, messages: []*sarama.ConsumerMessage{dead, orphan}},
+		producer:      producer,
+		topic:         "items-dlq",
+		idleTimeout:   50 * time.Millisecond,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	defer replayer.Close()
+
+	count, err := replayer.Replay(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("replayed %d messages, want 1", count)
+	}
+
+	if sent.Topic != "items" {
+		t.Errorf("topic = %s, want items", sent.Topic)
+	}
+	headers := headerMap(sent.Headers)
+	if headers[kafka.HeaderMessageID] != "m1" {
+		t.Errorf("header %s = %q, want m1", kafka.HeaderMessageID, headers[kafka.HeaderMessageID])
+	}
+	for key := range headers {
+		if strings.HasPrefix(key, "x-dlq-") {
+			t.Errorf("replayed message kept header %s", key)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package warehouse
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"vinted/otel-workshop/internal/chaos"
+	"vinted/otel-workshop/internal/customer"
+	"vinted/otel-workshop/internal/factory"
+	"vinted/otel-workshop/internal/product"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestContextCrossesKafka ships a product with the factory's KafkaShipper
+// and stores the message it produced with the warehouse handler, checking
+// that the trace and the customer.id baggage survive the trip.
+func TestContextCrossesKafka(t *testing.T) {
+	spans.Reset()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var sent *sarama.ProducerMessage
+	producer := mocks.NewSyncProducer(t, mocks.NewTestConfig())
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+	shipper := factory.NewKafkaShipperWithProducer(logger, producer, "items", product.EncodingJSON)
+	defer shipper.Close()
+
+	ctx, err := customer.ContextWithID(context.Background(), "c0123456789ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parcels := factory.NewParcels(&otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1})
+	if err := shipper.Ship(ctx, parcels).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// What the warehouse receives: the produced record as a ConsumerMessage.
+	value, err := sent.Value.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := &sarama.ConsumerMessage{Topic: sent.Topic, Value: value}
+	for _, h := range sent.Headers {
+		received.Headers = append(received.Headers, &sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+
+	storage := &flakyStorage{}
+	handler := &productHandler{
+		storage:     storage,
+		groupID:     "warehouse",
+		batchSize:   1,
+		deadLetters: unexpectedDeadLetterer{t},
+		chaos:       chaos.New(random.New(1)),
+		logger:      logger,
+	}
+	if marked := consumePartition(t, handler, []*sarama.ConsumerMessage{received}); len(marked) != 1 {
+		t.Fatalf("marked %d messages, want 1", len(marked))
+	}
+	if len(storage.stored) != 1 {
+		t.Fatalf("stored %d products, want 1", len(storage.stored))
+	}
+
+	publish, process := findSpan(t, "items publish"), findSpan(t, "items process")
+	if publish.SpanKind != trace.SpanKindProducer || process.SpanKind != trace.SpanKindConsumer {
+		t.Errorf("span kinds = %s, %s; want producer and consumer", publish.SpanKind, process.SpanKind)
+	}
+	if process.SpanContext.TraceID() != publish.SpanContext.TraceID() {
+		t.Errorf("process trace ID = %s, want %s", process.SpanContext.TraceID(), publish.SpanContext.TraceID())
+	}
+	if process.Parent.SpanID() != publish.SpanContext.SpanID() || !process.Parent.IsRemote() {
+		t.Errorf("process parent = %s, want the remote publish span %s", process.Parent.SpanID(), publish.SpanContext.SpanID())
+	}
+
+	var id string
+	for _, attr := range process.Attributes {
+		if string(attr.Key) == customer.BaggageKey {
+			id = attr.Value.AsString()
+		}
+	}
+	if id != "c0123456789ab" {
+		t.Errorf("process span %s = %q, want the baggage customer c0123456789ab", customer.BaggageKey, id)
+	}
+}
+
+func findSpan(t *testing.T, name string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range spans.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no %q span in %v", name, spans.GetSpans().Snapshots())
+
+	return tracetest.SpanStub{}
+}
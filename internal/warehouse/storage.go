@@ -2,44 +2,134 @@ package warehouse
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
+	"time"
+	"vinted/otel-workshop/internal/order"
+	"vinted/otel-workshop/internal/product"
 	"vinted/otel-workshop/internal/redis"
 	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
 )
 
+var meter = otel.Meter("vinted/otel-workshop/internal/warehouse")
+
 type WarehouseStorage interface {
-	Store(ctx context.Context, data []byte) error
+	// Store stores product once per messageID. An empty messageID disables
+	// deduplication.
+	Store(ctx context.Context, messageID string, product *otelworkshop.Product) error
+	// StoreMany is Store for several messages at once. Either all of them
+	// are stored or, on error, none is.
+	StoreMany(ctx context.Context, deliveries []Delivery) error
+}
+
+// Delivery is a product decoded from the message with MessageID.
+type Delivery struct {
+	MessageID string
+	Product   *otelworkshop.Product
 }
 
 type RedisWarehouseStorage struct {
 	redisClient *redis.WorkshopClient
+	orders      order.Store
 	logger      *slog.Logger
+	dedupeTTL   time.Duration
+	stored      metric.Int64Counter
+	duplicates  metric.Int64Counter
 }
 
-func NewRedisWarehouseStorage(logger *slog.Logger, addr string) *RedisWarehouseStorage {
+// NewRedisWarehouseStorage returns a storage that remembers the IDs of
+// stored messages for dedupeTTL, so that redelivered messages are not
+// counted twice.
+func NewRedisWarehouseStorage(logger *slog.Logger, addr string, orders order.Store, dedupeTTL time.Duration, hooks ...redis.Hook) *RedisWarehouseStorage {
+	stored, err := meter.Int64Counter("workshop.products.stored",
+		metric.WithDescription("Number of products stored in the warehouse."),
+		metric.WithUnit("{product}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	duplicates, err := meter.Int64Counter("workshop.products.duplicates",
+		metric.WithDescription("Number of redelivered product messages skipped by the warehouse."),
+		metric.WithUnit("{message}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
 	return &RedisWarehouseStorage{
-		redisClient: redis.NewWorkshopRedisClient(addr),
+		redisClient: redis.NewWorkshopRedisClient(addr, hooks...),
+		orders:      orders,
 		logger:      logger,
+		dedupeTTL:   dedupeTTL,
+		stored:      stored,
+		duplicates:  duplicates,
 	}
 }
 
-func (s *RedisWarehouseStorage) Store(ctx context.Context, data []byte) error {
-	var product otelworkshop.Product
+func (s *RedisWarehouseStorage) Ping(ctx context.Context) error {
+	return s.redisClient.Ping(ctx)
+}
 
-	s.logger.Info("storing product", "data", string(data))
+func (s *RedisWarehouseStorage) Close() error {
+	return s.redisClient.Close()
+}
 
-	err := json.Unmarshal(data, &product)
-	if err != nil {
-		s.logger.Error("failed to unmarshal message", "error", err)
-		return err
+func (s *RedisWarehouseStorage) Store(ctx context.Context, messageID string, p *otelworkshop.Product) error {
+	return s.StoreMany(ctx, []Delivery{{MessageID: messageID, Product: p}})
+}
+
+func (s *RedisWarehouseStorage) StoreMany(ctx context.Context, deliveries []Delivery) error {
+	ids := make([]string, len(deliveries))
+	products := make([]*otelworkshop.Product, len(deliveries))
+	for i, d := range deliveries {
+		s.logger.InfoContext(ctx, "storing product", "name", d.Product.Name, "color", d.Product.Color, "order_id", d.Product.OrderId, "message_id", d.MessageID)
+
+		ids[i] = warehouseMessageID(d.MessageID)
+		products[i] = &otelworkshop.Product{
+			Name:     d.Product.Name,
+			Color:    d.Product.Color,
+			Quantity: 1,
+		}
 	}
 
-	err = s.redisClient.Increment(ctx, &product, 1)
+	applied, err := s.redisClient.IncrementManyOnce(ctx, ids, s.dedupeTTL, products)
 	if err != nil {
-		s.logger.Error("failed to store product", "error", err)
+		s.logger.ErrorContext(ctx, "failed to store products", "count", len(deliveries), "error", err)
 		return err
 	}
 
+	stored := make(map[string]int64)
+	for i, d := range deliveries {
+		if !applied[i] {
+			s.logger.WarnContext(ctx, "skipping duplicate product message", "message_id", d.MessageID)
+			s.duplicates.Add(ctx, 1, metric.WithAttributes(product.Attributes(d.Product)...))
+			continue
+		}
+
+		s.stored.Add(ctx, 1, metric.WithAttributes(product.Attributes(d.Product)...))
+		if d.Product.OrderId != "" {
+			stored[d.Product.OrderId]++
+		}
+	}
+
+	for orderID, count := range stored {
+		if err := s.orders.AddStored(ctx, orderID, count); err != nil {
+			s.logger.WarnContext(ctx, "failed to update order", "order_id", orderID, "error", err)
+		}
+	}
+
 	return nil
 }
+
+// warehouseMessageID namespaces messageID for deduplication. An empty
+// messageID stays empty, which disables it.
+func warehouseMessageID(messageID string) string {
+	if messageID == "" {
+		return ""
+	}
+
+	return "warehouse:" + messageID
+}
@@ -3,11 +3,30 @@ package warehouse
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"strconv"
+	"time"
+
+	"vinted/otel-workshop/internal/chaos"
+	"vinted/otel-workshop/internal/kafka"
+	"vinted/otel-workshop/internal/product"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
 
 	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("vinted/otel-workshop/internal/warehouse")
+
+// ErrMalformedProduct is returned for messages that can never be decoded, so
+// retrying them is pointless.
+var ErrMalformedProduct = errors.New("malformed product")
+
 type Warehouse interface {
 	PickAndStore(ctx context.Context) error
 }
@@ -19,7 +38,9 @@ type KafkaRedisWarehouse struct {
 	logger        *slog.Logger
 }
 
-func NewKafkaRedisWarehouse(logger *slog.Logger, brokerAddresses, topics []string, groupID string, storage WarehouseStorage) (*KafkaRedisWarehouse, error) {
+// NewKafkaRedisWarehouse returns a warehouse that stores up to batchSize
+// already received messages with a single StoreMany call.
+func NewKafkaRedisWarehouse(logger *slog.Logger, brokerAddresses, topics []string, groupID string, storage WarehouseStorage, batchSize int, retry RetryPolicy, deadLetters DeadLetterer, injector *chaos.Injector) (*KafkaRedisWarehouse, error) {
 	saramaConfig := sarama.NewConfig()
 	consumerGroup, err := sarama.NewConsumerGroup(brokerAddresses, groupID, saramaConfig)
 	if err != nil {
@@ -29,9 +50,13 @@ func NewKafkaRedisWarehouse(logger *slog.Logger, brokerAddresses, topics []strin
 	return &KafkaRedisWarehouse{
 		consumerGroup: consumerGroup,
 		handler: &productHandler{
-			storage: storage,
-			ready:   make(chan bool),
-			logger:  logger,
+			storage:     storage,
+			groupID:     groupID,
+			batchSize:   max(batchSize, 1),
+			retry:       retry,
+			deadLetters: deadLetters,
+			chaos:       injector,
+			logger:      logger,
 		},
 		topics: topics,
 		logger: logger,
@@ -41,54 +66,246 @@ func NewKafkaRedisWarehouse(logger *slog.Logger, brokerAddresses, topics []strin
 func (w *KafkaRedisWarehouse) PickAndStore(ctx context.Context) error {
 	if err := w.consumerGroup.Consume(ctx, w.topics, w.handler); err != nil {
 		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
-			w.logger.Info("consumer group closed")
+			w.logger.InfoContext(ctx, "consumer group closed")
 			return err
 		}
-		w.logger.Error("failed to consume messages", "error", err)
+		w.logger.ErrorContext(ctx, "failed to consume messages", "error", err)
 	}
 
 	if ctx.Err() != nil {
-		w.logger.Error("context canceled", "error", ctx.Err())
+		w.logger.InfoContext(ctx, "context canceled", "error", ctx.Err())
 		return ctx.Err()
 	}
 
 	return nil
 }
 
+func (w *KafkaRedisWarehouse) Close() error {
+	return w.consumerGroup.Close()
+}
+
 type productHandler struct {
-	storage WarehouseStorage
-	ready   chan bool
-	logger  *slog.Logger
+	storage     WarehouseStorage
+	groupID     string
+	batchSize   int
+	retry       RetryPolicy
+	deadLetters DeadLetterer
+	chaos       *chaos.Injector
+	logger      *slog.Logger
 }
 
-func (p *productHandler) Setup(sarama.ConsumerGroupSession) error {
-	close(p.ready)
+func (h *productHandler) Setup(sarama.ConsumerGroupSession) error {
 	return nil
 }
 
-func (p *productHandler) Cleanup(sarama.ConsumerGroupSession) error {
+func (h *productHandler) Cleanup(sarama.ConsumerGroupSession) error {
 	return nil
 }
 
-func (p *productHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+func (h *productHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for {
 		select {
 		case message, ok := <-claim.Messages():
 			if !ok {
-				p.logger.Info("message channel was closed")
+				h.logger.InfoContext(session.Context(), "message channel was closed")
 				return nil
 			}
 
-			p.logger.Info("message claimed", "value", string(message.Value), "timestamp", message.Timestamp, "topic", message.Topic)
-
-			err := p.storage.Store(session.Context(), message.Value)
-			if err != nil {
-				p.logger.Error("failed to store", "error", err)
+			if err := h.handleBatch(session, h.collect(message, claim.Messages())); err != nil {
+				return err
 			}
-
-			session.MarkMessage(message, "")
 		case <-session.Context().Done():
 			return nil
 		}
 	}
 }
+
+// collect adds to first the messages already received behind it, up to the
+// batch size, without waiting for more.
+func (h *productHandler) collect(first *sarama.ConsumerMessage, messages <-chan *sarama.ConsumerMessage) []*sarama.ConsumerMessage {
+	batch := []*sarama.ConsumerMessage{first}
+	for len(batch) < h.batchSize {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, message)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+// claimed is a message being handled, along with its process span.
+type claimed struct {
+	ctx      context.Context
+	span     trace.Span
+	message  *sarama.ConsumerMessage
+	delivery Delivery
+	dropped  bool
+	err      error
+}
+
+// handleBatch stores the messages with a single StoreMany call, falling back
+// to storing them one by one, with retries, if that fails. Messages that
+// can't be stored are dead-lettered. A message is left unmarked if the
+// session ends mid-retry or dead-lettering fails, so that it is redelivered.
+func (h *productHandler) handleBatch(session sarama.ConsumerGroupSession, messages []*sarama.ConsumerMessage) error {
+	batch := make([]*claimed, len(messages))
+	var pending []*claimed
+	for i, message := range messages {
+		c := h.claim(session, message)
+		defer c.span.End()
+
+		batch[i] = c
+		if !c.dropped && c.err == nil {
+			pending = append(pending, c)
+		}
+	}
+
+	// A single message is stored in its own trace.
+	if len(pending) == 1 || h.storeMany(session.Context(), pending) != nil {
+		for _, c := range pending {
+			c.err = h.store(c.ctx, c.delivery.MessageID, c.delivery.Product)
+		}
+	}
+
+	for _, c := range batch {
+		if err := h.finish(session, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// claim starts the process span of message and decodes it.
+func (h *productHandler) claim(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) *claimed {
+	ctx := kafka.Extract(session.Context(), message)
+	ctx, span := tracer.Start(ctx, message.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(message.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(int(message.Partition))),
+			semconv.MessagingKafkaOffset(int(message.Offset)),
+			semconv.MessagingConsumerGroupName(h.groupID),
+			semconv.MessagingMessageBodySize(len(message.Value)),
+		),
+	)
+
+	c := &claimed{ctx: ctx, span: span, message: message}
+
+	if id := kafka.NewConsumerMessageCarrier(message).Get(kafka.HeaderMessageID); id != "" {
+		span.SetAttributes(semconv.MessagingMessageID(id))
+	}
+
+	h.logger.InfoContext(ctx, "message claimed", "size", len(message.Value), "timestamp", message.Timestamp, "topic", message.Topic)
+
+	if h.chaos.Drop(ctx, chaos.TargetKafkaConsume) {
+		h.logger.WarnContext(ctx, "dropped message")
+		c.dropped = true
+		return c
+	}
+
+	c.err = h.chaos.Try(ctx, chaos.TargetKafkaConsume)
+	if c.err == nil {
+		c.delivery, c.err = decode(message)
+	}
+
+	return c
+}
+
+// finish dead-letters a message that could not be stored and marks it.
+func (h *productHandler) finish(session sarama.ConsumerGroupSession, c *claimed) error {
+	if c.err != nil && c.ctx.Err() != nil {
+		return nil
+	}
+	if c.err != nil {
+		c.span.RecordError(c.err)
+		c.span.SetStatus(codes.Error, c.err.Error())
+		h.logger.ErrorContext(c.ctx, "failed to store", "error", c.err)
+
+		if err := h.deadLetters.DeadLetter(c.ctx, c.message, c.err); err != nil {
+			c.span.RecordError(err)
+			h.logger.ErrorContext(c.ctx, "failed to dead-letter message", "error", err)
+			return fmt.Errorf("dead-letter message: %w", err)
+		}
+	}
+
+	session.MarkMessage(c.message, "")
+
+	return nil
+}
+
+func decode(message *sarama.ConsumerMessage) (Delivery, error) {
+	carrier := kafka.NewConsumerMessageCarrier(message)
+
+	p, err := product.Unmarshal(
+		carrier.Get(kafka.HeaderContentType),
+		carrier.Get(kafka.HeaderSchemaVersion),
+		message.Value,
+	)
+	if err != nil {
+		return Delivery{}, fmt.Errorf("%w: %v", ErrMalformedProduct, err)
+	}
+
+	return Delivery{MessageID: carrier.Get(kafka.HeaderMessageID), Product: p}, nil
+}
+
+// storeMany stores the pending messages in one go, in a span of its own
+// linked to the process span of every message in the batch.
+func (h *productHandler) storeMany(ctx context.Context, pending []*claimed) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	links := make([]trace.Link, len(pending))
+	deliveries := make([]Delivery, len(pending))
+	for i, c := range pending {
+		links[i] = trace.Link{SpanContext: c.span.SpanContext()}
+		deliveries[i] = c.delivery
+	}
+
+	ctx, span := tracer.Start(ctx, "store batch",
+		trace.WithLinks(links...),
+		trace.WithAttributes(semconv.MessagingBatchMessageCount(len(pending))),
+	)
+	defer span.End()
+
+	err := h.storage.StoreMany(ctx, deliveries)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		h.logger.WarnContext(ctx, "failed to store batch, storing one by one", "count", len(pending), "error", err)
+	}
+
+	return err
+}
+
+func (h *productHandler) store(ctx context.Context, messageID string, p *otelworkshop.Product) error {
+	for attempt := 0; ; attempt++ {
+		err := h.storage.Store(ctx, messageID, p)
+		if err == nil || errors.Is(err, ErrMalformedProduct) || attempt >= h.retry.Retries {
+			return err
+		}
+
+		backoff := h.retry.backoff(attempt)
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("retry.attempt", attempt+1),
+			attribute.String("retry.backoff", backoff.String()),
+			attribute.String("error.message", err.Error()),
+		))
+		h.logger.WarnContext(ctx, "failed to store, retrying", "attempt", attempt+1, "backoff", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package warehouse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"vinted/otel-workshop/internal/chaos"
+	"vinted/otel-workshop/internal/kafka"
+	"vinted/otel-workshop/internal/order"
+	"vinted/otel-workshop/internal/product"
+	"vinted/otel-workshop/internal/random"
+	"vinted/otel-workshop/internal/telemetry"
+	"vinted/otel-workshop/pb/genproto/otelworkshop"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/alicebob/miniredis/v2"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type testSession struct {
+	ctx    context.Context
+	mux    sync.Mutex
+	marked []int64
+}
+
+func (s *testSession) Claims() map[string][]int32               { return nil }
+func (s *testSession) MemberID() string                         { return "test" }
+func (s *testSession) GenerationID() int32                      { return 1 }
+func (s *testSession) MarkOffset(string, int32, int64, string)  {}
+func (s *testSession) Commit()                                  {}
+func (s *testSession) ResetOffset(string, int32, int64, string) {}
+func (s *testSession) Context() context.Context                 { return s.ctx }
+func (s *testSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type testClaim struct {
+	sarama.PartitionConsumer
+}
+
+func (c testClaim) Topic() string        { return "items" }
+func (c testClaim) Partition() int32     { return 0 }
+func (c testClaim) InitialOffset() int64 { return sarama.OffsetOldest }
+
+// unexpectedDeadLetterer fails the test if any message is dead-lettered.
+type unexpectedDeadLetterer struct {
+	t *testing.T
+}
+
+func (d unexpectedDeadLetterer) DeadLetter(_ context.Context, message *sarama.ConsumerMessage, cause error) error {
+	d.t.Errorf("message %d dead-lettered: %v", message.Offset, cause)
+	return nil
+}
+
+func productMessage(t *testing.T, messageID string, p *otelworkshop.Product) *sarama.ConsumerMessage {
+	t.Helper()
+
+	value, err := product.Marshal(product.EncodingJSON, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &sarama.ConsumerMessage{
+		Topic: "items",
+		Value: value,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte(kafka.HeaderContentType), Value: []byte(product.ContentTypeJSON)},
+			{Key: []byte(kafka.HeaderMessageID), Value: []byte(messageID)},
+		},
+	}
+}
+
+// newTestClaim returns a claim that yields messages through a mock partition
+// consumer and then closes.
+func newTestClaim(t *testing.T, messages []*sarama.ConsumerMessage) testClaim {
+	t.Helper()
+
+	consumer := mocks.NewConsumer(t, nil)
+	expectation := consumer.ExpectConsumePartition("items", 0, sarama.OffsetOldest)
+	for _, message := range messages {
+		m := *message
+		expectation.YieldMessage(&m)
+	}
+
+	partition, err := consumer.ConsumePartition("items", 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partition.AsyncClose()
+
+	return testClaim{partition}
+}
+
+// consumePartition feeds messages to handler, as a consumer group session
+// would, and returns the marked offsets.
+func consumePartition(t *testing.T, handler sarama.ConsumerGroupHandler, messages []*sarama.ConsumerMessage) []int64 {
+	t.Helper()
+
+	session := &testSession{ctx: context.Background()}
+	if err := handler.ConsumeClaim(session, newTestClaim(t, messages)); err != nil {
+		t.Fatal(err)
+	}
+
+	return session.marked
+}
+
+// spans and reader receive the telemetry of every test: package tracers and
+// meters delegate to the first global providers only, so telemetry is set up
+// once, the way the services do it.
+var (
+	spans  = tracetest.NewInMemoryExporter()
+	reader = sdkmetric.NewManualReader()
+)
+
+// discardLogs is a log exporter that drops every record.
+type discardLogs struct{}
+
+func (discardLogs) Export(context.Context, []sdklog.Record) error { return nil }
+func (discardLogs) Shutdown(context.Context) error                { return nil }
+func (discardLogs) ForceFlush(context.Context) error              { return nil }
+
+func TestMain(m *testing.M) {
+	shutdown, err := telemetry.Setup(context.Background(), "warehouse-test",
+		telemetry.WithSpanExporter(spans),
+		telemetry.WithMetricReader(reader),
+		telemetry.WithLogExporter(discardLogs{}),
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	_ = shutdown(context.Background())
+	os.Exit(code)
+}
+
+func TestReplayedPartitionIsStoredOnce(t *testing.T) {
+	mr := miniredis.RunT(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	storage := NewRedisWarehouseStorage(logger, mr.Addr(), order.NewMemoryStore(), time.Hour)
+	defer storage.Close()
+
+	handler := &productHandler{
+		storage:     storage,
+		groupID:     "warehouse",
+		batchSize:   2,
+		deadLetters: unexpectedDeadLetterer{t},
+		chaos:       chaos.New(random.New(1)),
+		logger:      logger,
+	}
+
+	messages := []*sarama.ConsumerMessage{
+		productMessage(t, "m1", &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1}),
+		productMessage(t, "m2", &otelworkshop.Product{Name: "hat", Color: "red", Quantity: 1}),
+		productMessage(t, "m3", &otelworkshop.Product{Name: "socks", Color: "blue", Quantity: 1}),
+	}
+
+	for pass := 1; pass <= 2; pass++ {
+		if marked := consumePartition(t, handler, messages); len(marked) != len(messages) {
+			t.Fatalf("pass %d: marked %d messages, want %d", pass, len(marked), len(messages))
+		}
+	}
+
+	if got, _ := mr.Get("hat:red"); got != "2" {
+		t.Errorf("hat:red = %s, want 2", got)
+	}
+	if got, _ := mr.Get("socks:blue"); got != "1" {
+		t.Errorf("socks:blue = %s, want 1", got)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	if got := sum(rm, "workshop.products.stored"); got != 3 {
+		t.Errorf("workshop.products.stored = %d, want 3", got)
+	}
+	if got := sum(rm, "workshop.products.duplicates"); got != 3 {
+		t.Errorf("workshop.products.duplicates = %d, want 3", got)
+	}
+}
+
+func sum(rm metricdata.ResourceMetrics, name string) int64 {
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			if data, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, point := range data.DataPoints {
+					total += point.Value
+				}
+			}
+		}
+	}
+
+	return total
+}
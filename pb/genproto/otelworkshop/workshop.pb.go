@@ -1,7 +1,7 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.34.2
-// 	protoc        v4.25.2
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
 // source: workshop.proto
 
 package otelworkshop
@@ -9,8 +9,10 @@ package otelworkshop
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
+	unsafe "unsafe"
 )
 
 const (
@@ -21,18 +23,16 @@ const (
 )
 
 type Empty struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Empty) Reset() {
 	*x = Empty{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_workshop_proto_msgTypes[0]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
+	mi := &file_workshop_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
 func (x *Empty) String() string {
@@ -43,7 +43,7 @@ func (*Empty) ProtoMessage() {}
 
 func (x *Empty) ProtoReflect() protoreflect.Message {
 	mi := &file_workshop_proto_msgTypes[0]
-	if protoimpl.UnsafeEnabled && x != nil {
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -59,22 +59,24 @@ func (*Empty) Descriptor() ([]byte, []int) {
 }
 
 type Product struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Name     string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Color    string                 `protobuf:"bytes,2,opt,name=color,proto3" json:"color,omitempty"`
+	Quantity int64                  `protobuf:"varint,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	OrderId  string                 `protobuf:"bytes,4,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	// Unit price in minor units of currency, e.g. cents.
+	Price int64 `protobuf:"varint,5,opt,name=price,proto3" json:"price,omitempty"`
+	// ISO 4217 currency code.
+	Currency      string `protobuf:"bytes,6,opt,name=currency,proto3" json:"currency,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Name     string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Color    string `protobuf:"bytes,2,opt,name=color,proto3" json:"color,omitempty"`
-	Quantity int64  `protobuf:"varint,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Product) Reset() {
 	*x = Product{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_workshop_proto_msgTypes[1]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
+	mi := &file_workshop_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
 func (x *Product) String() string {
@@ -85,7 +87,7 @@ func (*Product) ProtoMessage() {}
 
 func (x *Product) ProtoReflect() protoreflect.Message {
 	mi := &file_workshop_proto_msgTypes[1]
-	if protoimpl.UnsafeEnabled && x != nil {
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -121,21 +123,166 @@ func (x *Product) GetQuantity() int64 {
 	return 0
 }
 
-type ListProductsResponse struct {
-	state         protoimpl.MessageState
+func (x *Product) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *Product) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Product) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+// ProductFilter matches products on every field that is set.
+type ProductFilter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Color         string                 `protobuf:"bytes,2,opt,name=color,proto3" json:"color,omitempty"`
+	MinQuantity   *int64                 `protobuf:"varint,3,opt,name=min_quantity,json=minQuantity,proto3,oneof" json:"min_quantity,omitempty"`
+	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductFilter) Reset() {
+	*x = ProductFilter{}
+	mi := &file_workshop_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductFilter) ProtoMessage() {}
+
+func (x *ProductFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductFilter.ProtoReflect.Descriptor instead.
+func (*ProductFilter) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ProductFilter) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetColor() string {
+	if x != nil {
+		return x.Color
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetMinQuantity() int64 {
+	if x != nil && x.MinQuantity != nil {
+		return *x.MinQuantity
+	}
+	return 0
+}
+
+// ListProductsRequest replaces Empty on the wire, so an empty request still
+// lists every product.
+type ListProductsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Filter *ProductFilter         `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	// Maximum number of products to return. Zero returns all of them.
+	PageSize int32 `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token from a previous response.
+	PageToken     string `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-	Products []*Product `protobuf:"bytes,1,rep,name=products,proto3" json:"products,omitempty"`
+func (x *ListProductsRequest) Reset() {
+	*x = ListProductsRequest{}
+	mi := &file_workshop_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *ListProductsResponse) Reset() {
-	*x = ListProductsResponse{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_workshop_proto_msgTypes[2]
+func (x *ListProductsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProductsRequest) ProtoMessage() {}
+
+func (x *ListProductsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[3]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProductsRequest.ProtoReflect.Descriptor instead.
+func (*ListProductsRequest) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListProductsRequest) GetFilter() *ProductFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *ListProductsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListProductsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListProductsResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Products []*Product             `protobuf:"bytes,1,rep,name=products,proto3" json:"products,omitempty"`
+	// Empty on the last page.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProductsResponse) Reset() {
+	*x = ListProductsResponse{}
+	mi := &file_workshop_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
 func (x *ListProductsResponse) String() string {
@@ -145,8 +292,8 @@ func (x *ListProductsResponse) String() string {
 func (*ListProductsResponse) ProtoMessage() {}
 
 func (x *ListProductsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_workshop_proto_msgTypes[2]
-	if protoimpl.UnsafeEnabled && x != nil {
+	mi := &file_workshop_proto_msgTypes[4]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -158,7 +305,7 @@ func (x *ListProductsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListProductsResponse.ProtoReflect.Descriptor instead.
 func (*ListProductsResponse) Descriptor() ([]byte, []int) {
-	return file_workshop_proto_rawDescGZIP(), []int{2}
+	return file_workshop_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *ListProductsResponse) GetProducts() []*Product {
@@ -168,23 +315,124 @@ func (x *ListProductsResponse) GetProducts() []*Product {
 	return nil
 }
 
-type BuyProductRequest struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+func (x *ListProductsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type WatchInventoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *ProductFilter         `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
 	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-	Name    string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Surname string   `protobuf:"bytes,2,opt,name=surname,proto3" json:"surname,omitempty"`
-	Product *Product `protobuf:"bytes,3,opt,name=product,proto3" json:"product,omitempty"`
+func (x *WatchInventoryRequest) Reset() {
+	*x = WatchInventoryRequest{}
+	mi := &file_workshop_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *BuyProductRequest) Reset() {
-	*x = BuyProductRequest{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_workshop_proto_msgTypes[3]
+func (x *WatchInventoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchInventoryRequest) ProtoMessage() {}
+
+func (x *WatchInventoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchInventoryRequest.ProtoReflect.Descriptor instead.
+func (*WatchInventoryRequest) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WatchInventoryRequest) GetFilter() *ProductFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type InventoryChange struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The product with its new quantity.
+	Product          *Product `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	PreviousQuantity int64    `protobuf:"varint,2,opt,name=previous_quantity,json=previousQuantity,proto3" json:"previous_quantity,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *InventoryChange) Reset() {
+	*x = InventoryChange{}
+	mi := &file_workshop_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InventoryChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InventoryChange) ProtoMessage() {}
+
+func (x *InventoryChange) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[6]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InventoryChange.ProtoReflect.Descriptor instead.
+func (*InventoryChange) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *InventoryChange) GetProduct() *Product {
+	if x != nil {
+		return x.Product
 	}
+	return nil
+}
+
+func (x *InventoryChange) GetPreviousQuantity() int64 {
+	if x != nil {
+		return x.PreviousQuantity
+	}
+	return 0
+}
+
+type BuyProductRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Surname       string                 `protobuf:"bytes,2,opt,name=surname,proto3" json:"surname,omitempty"`
+	Product       *Product               `protobuf:"bytes,3,opt,name=product,proto3" json:"product,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BuyProductRequest) Reset() {
+	*x = BuyProductRequest{}
+	mi := &file_workshop_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
 func (x *BuyProductRequest) String() string {
@@ -194,8 +442,8 @@ func (x *BuyProductRequest) String() string {
 func (*BuyProductRequest) ProtoMessage() {}
 
 func (x *BuyProductRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_workshop_proto_msgTypes[3]
-	if protoimpl.UnsafeEnabled && x != nil {
+	mi := &file_workshop_proto_msgTypes[7]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -207,7 +455,7 @@ func (x *BuyProductRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BuyProductRequest.ProtoReflect.Descriptor instead.
 func (*BuyProductRequest) Descriptor() ([]byte, []int) {
-	return file_workshop_proto_rawDescGZIP(), []int{3}
+	return file_workshop_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *BuyProductRequest) GetName() string {
@@ -231,74 +479,633 @@ func (x *BuyProductRequest) GetProduct() *Product {
 	return nil
 }
 
-var File_workshop_proto protoreflect.FileDescriptor
+type CheckoutRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Name    string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Surname string                 `protobuf:"bytes,2,opt,name=surname,proto3" json:"surname,omitempty"`
+	// Products to buy; a product may appear on several lines.
+	Lines         []*Product `protobuf:"bytes,3,rep,name=lines,proto3" json:"lines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckoutRequest) Reset() {
+	*x = CheckoutRequest{}
+	mi := &file_workshop_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckoutRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckoutRequest) ProtoMessage() {}
+
+func (x *CheckoutRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckoutRequest.ProtoReflect.Descriptor instead.
+func (*CheckoutRequest) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CheckoutRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CheckoutRequest) GetSurname() string {
+	if x != nil {
+		return x.Surname
+	}
+	return ""
+}
+
+func (x *CheckoutRequest) GetLines() []*Product {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+type CheckoutResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The bought lines with their unit prices.
+	Lines []*Product `protobuf:"bytes,1,rep,name=lines,proto3" json:"lines,omitempty"`
+	// Sum of every line's price times quantity, in minor units of currency.
+	Total         int64  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Currency      string `protobuf:"bytes,3,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckoutResponse) Reset() {
+	*x = CheckoutResponse{}
+	mi := &file_workshop_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckoutResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckoutResponse) ProtoMessage() {}
+
+func (x *CheckoutResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckoutResponse.ProtoReflect.Descriptor instead.
+func (*CheckoutResponse) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CheckoutResponse) GetLines() []*Product {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+func (x *CheckoutResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *CheckoutResponse) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+type ReserveProductRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Surname       string                 `protobuf:"bytes,2,opt,name=surname,proto3" json:"surname,omitempty"`
+	Product       *Product               `protobuf:"bytes,3,opt,name=product,proto3" json:"product,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReserveProductRequest) Reset() {
+	*x = ReserveProductRequest{}
+	mi := &file_workshop_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReserveProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReserveProductRequest) ProtoMessage() {}
+
+func (x *ReserveProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReserveProductRequest.ProtoReflect.Descriptor instead.
+func (*ReserveProductRequest) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ReserveProductRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ReserveProductRequest) GetSurname() string {
+	if x != nil {
+		return x.Surname
+	}
+	return ""
+}
+
+func (x *ReserveProductRequest) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+type Reservation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// The reserved product with its unit price.
+	Product       *Product               `protobuf:"bytes,2,opt,name=product,proto3" json:"product,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Reservation) Reset() {
+	*x = Reservation{}
+	mi := &file_workshop_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reservation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reservation) ProtoMessage() {}
+
+func (x *Reservation) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reservation.ProtoReflect.Descriptor instead.
+func (*Reservation) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Reservation) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Reservation) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+func (x *Reservation) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+type ConfirmReservationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfirmReservationRequest) Reset() {
+	*x = ConfirmReservationRequest{}
+	mi := &file_workshop_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfirmReservationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfirmReservationRequest) ProtoMessage() {}
+
+func (x *ConfirmReservationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfirmReservationRequest.ProtoReflect.Descriptor instead.
+func (*ConfirmReservationRequest) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ConfirmReservationRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CancelReservationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelReservationRequest) Reset() {
+	*x = CancelReservationRequest{}
+	mi := &file_workshop_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelReservationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelReservationRequest) ProtoMessage() {}
+
+func (x *CancelReservationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelReservationRequest.ProtoReflect.Descriptor instead.
+func (*CancelReservationRequest) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CancelReservationRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetPurchaseHistoryRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	CustomerId string                 `protobuf:"bytes,1,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	// Maximum number of purchases to return. Zero returns all that are kept.
+	Limit         int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPurchaseHistoryRequest) Reset() {
+	*x = GetPurchaseHistoryRequest{}
+	mi := &file_workshop_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
 
-var file_workshop_proto_rawDesc = []byte{
-	0x0a, 0x0e, 0x77, 0x6f, 0x72, 0x6b, 0x73, 0x68, 0x6f, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x12, 0x0c, 0x6f, 0x74, 0x65, 0x6c, 0x77, 0x6f, 0x72, 0x6b, 0x73, 0x68, 0x6f, 0x70, 0x22, 0x07,
-	0x0a, 0x05, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x4f, 0x0a, 0x07, 0x50, 0x72, 0x6f, 0x64, 0x75,
-	0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x12, 0x1a, 0x0a, 0x08,
-	0x71, 0x75, 0x61, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
-	0x71, 0x75, 0x61, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x22, 0x49, 0x0a, 0x14, 0x4c, 0x69, 0x73, 0x74,
-	0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x12, 0x31, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03,
-	0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6f, 0x74, 0x65, 0x6c, 0x77, 0x6f, 0x72, 0x6b, 0x73, 0x68, 0x6f,
-	0x70, 0x2e, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x64, 0x75,
-	0x63, 0x74, 0x73, 0x22, 0x72, 0x0a, 0x11, 0x42, 0x75, 0x79, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63,
-	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07,
-	0x73, 0x75, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73,
-	0x75, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x2f, 0x0a, 0x07, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63,
-	0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6f, 0x74, 0x65, 0x6c, 0x77, 0x6f,
-	0x72, 0x6b, 0x73, 0x68, 0x6f, 0x70, 0x2e, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x52, 0x07,
-	0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x32, 0xa0, 0x01, 0x0a, 0x0b, 0x53, 0x68, 0x6f, 0x70,
-	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x49, 0x0a, 0x0c, 0x4c, 0x69, 0x73, 0x74, 0x50,
-	0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x12, 0x13, 0x2e, 0x6f, 0x74, 0x65, 0x6c, 0x77, 0x6f,
-	0x72, 0x6b, 0x73, 0x68, 0x6f, 0x70, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x22, 0x2e, 0x6f,
-	0x74, 0x65, 0x6c, 0x77, 0x6f, 0x72, 0x6b, 0x73, 0x68, 0x6f, 0x70, 0x2e, 0x4c, 0x69, 0x73, 0x74,
-	0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x22, 0x00, 0x12, 0x46, 0x0a, 0x0a, 0x42, 0x75, 0x79, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74,
-	0x12, 0x1f, 0x2e, 0x6f, 0x74, 0x65, 0x6c, 0x77, 0x6f, 0x72, 0x6b, 0x73, 0x68, 0x6f, 0x70, 0x2e,
-	0x42, 0x75, 0x79, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x1a, 0x15, 0x2e, 0x6f, 0x74, 0x65, 0x6c, 0x77, 0x6f, 0x72, 0x6b, 0x73, 0x68, 0x6f, 0x70,
-	0x2e, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x22, 0x00, 0x42, 0x17, 0x5a, 0x15, 0x67, 0x65,
-	0x6e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x6f, 0x74, 0x65, 0x6c, 0x77, 0x6f, 0x72, 0x6b, 0x73,
-	0x68, 0x6f, 0x70, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+func (x *GetPurchaseHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPurchaseHistoryRequest) ProtoMessage() {}
+
+func (x *GetPurchaseHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
+// Deprecated: Use GetPurchaseHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetPurchaseHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetPurchaseHistoryRequest) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *GetPurchaseHistoryRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type Purchase struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The bought product with its unit price.
+	Product       *Product               `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	PurchasedAt   *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=purchased_at,json=purchasedAt,proto3" json:"purchased_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Purchase) Reset() {
+	*x = Purchase{}
+	mi := &file_workshop_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Purchase) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Purchase) ProtoMessage() {}
+
+func (x *Purchase) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Purchase.ProtoReflect.Descriptor instead.
+func (*Purchase) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Purchase) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+func (x *Purchase) GetPurchasedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PurchasedAt
+	}
+	return nil
+}
+
+type PurchaseHistory struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Newest first.
+	Purchases     []*Purchase `protobuf:"bytes,1,rep,name=purchases,proto3" json:"purchases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurchaseHistory) Reset() {
+	*x = PurchaseHistory{}
+	mi := &file_workshop_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurchaseHistory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurchaseHistory) ProtoMessage() {}
+
+func (x *PurchaseHistory) ProtoReflect() protoreflect.Message {
+	mi := &file_workshop_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurchaseHistory.ProtoReflect.Descriptor instead.
+func (*PurchaseHistory) Descriptor() ([]byte, []int) {
+	return file_workshop_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PurchaseHistory) GetPurchases() []*Purchase {
+	if x != nil {
+		return x.Purchases
+	}
+	return nil
+}
+
+var File_workshop_proto protoreflect.FileDescriptor
+
+const file_workshop_proto_rawDesc = "" +
+	"\n" +
+	"\x0eworkshop.proto\x12\fotelworkshop\x1a\x1fgoogle/protobuf/timestamp.proto\"\a\n" +
+	"\x05Empty\"\x9c\x01\n" +
+	"\aProduct\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05color\x18\x02 \x01(\tR\x05color\x12\x1a\n" +
+	"\bquantity\x18\x03 \x01(\x03R\bquantity\x12\x19\n" +
+	"\border_id\x18\x04 \x01(\tR\aorderId\x12\x14\n" +
+	"\x05price\x18\x05 \x01(\x03R\x05price\x12\x1a\n" +
+	"\bcurrency\x18\x06 \x01(\tR\bcurrency\"r\n" +
+	"\rProductFilter\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05color\x18\x02 \x01(\tR\x05color\x12&\n" +
+	"\fmin_quantity\x18\x03 \x01(\x03H\x00R\vminQuantity\x88\x01\x01B\x0f\n" +
+	"\r_min_quantity\"\x86\x01\n" +
+	"\x13ListProductsRequest\x123\n" +
+	"\x06filter\x18\x01 \x01(\v2\x1b.otelworkshop.ProductFilterR\x06filter\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"q\n" +
+	"\x14ListProductsResponse\x121\n" +
+	"\bproducts\x18\x01 \x03(\v2\x15.otelworkshop.ProductR\bproducts\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"L\n" +
+	"\x15WatchInventoryRequest\x123\n" +
+	"\x06filter\x18\x01 \x01(\v2\x1b.otelworkshop.ProductFilterR\x06filter\"o\n" +
+	"\x0fInventoryChange\x12/\n" +
+	"\aproduct\x18\x01 \x01(\v2\x15.otelworkshop.ProductR\aproduct\x12+\n" +
+	"\x11previous_quantity\x18\x02 \x01(\x03R\x10previousQuantity\"r\n" +
+	"\x11BuyProductRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\asurname\x18\x02 \x01(\tR\asurname\x12/\n" +
+	"\aproduct\x18\x03 \x01(\v2\x15.otelworkshop.ProductR\aproduct\"l\n" +
+	"\x0fCheckoutRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\asurname\x18\x02 \x01(\tR\asurname\x12+\n" +
+	"\x05lines\x18\x03 \x03(\v2\x15.otelworkshop.ProductR\x05lines\"q\n" +
+	"\x10CheckoutResponse\x12+\n" +
+	"\x05lines\x18\x01 \x03(\v2\x15.otelworkshop.ProductR\x05lines\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\"v\n" +
+	"\x15ReserveProductRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\asurname\x18\x02 \x01(\tR\asurname\x12/\n" +
+	"\aproduct\x18\x03 \x01(\v2\x15.otelworkshop.ProductR\aproduct\"\x89\x01\n" +
+	"\vReservation\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12/\n" +
+	"\aproduct\x18\x02 \x01(\v2\x15.otelworkshop.ProductR\aproduct\x129\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\"+\n" +
+	"\x19ConfirmReservationRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"*\n" +
+	"\x18CancelReservationRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"R\n" +
+	"\x19GetPurchaseHistoryRequest\x12\x1f\n" +
+	"\vcustomer_id\x18\x01 \x01(\tR\n" +
+	"customerId\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\"z\n" +
+	"\bPurchase\x12/\n" +
+	"\aproduct\x18\x01 \x01(\v2\x15.otelworkshop.ProductR\aproduct\x12=\n" +
+	"\fpurchased_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\vpurchasedAt\"G\n" +
+	"\x0fPurchaseHistory\x124\n" +
+	"\tpurchases\x18\x01 \x03(\v2\x16.otelworkshop.PurchaseR\tpurchases2\xb5\x05\n" +
+	"\vShopService\x12W\n" +
+	"\fListProducts\x12!.otelworkshop.ListProductsRequest\x1a\".otelworkshop.ListProductsResponse\"\x00\x12F\n" +
+	"\n" +
+	"BuyProduct\x12\x1f.otelworkshop.BuyProductRequest\x1a\x15.otelworkshop.Product\"\x00\x12K\n" +
+	"\bCheckout\x12\x1d.otelworkshop.CheckoutRequest\x1a\x1e.otelworkshop.CheckoutResponse\"\x00\x12R\n" +
+	"\x0eReserveProduct\x12#.otelworkshop.ReserveProductRequest\x1a\x19.otelworkshop.Reservation\"\x00\x12V\n" +
+	"\x12ConfirmReservation\x12'.otelworkshop.ConfirmReservationRequest\x1a\x15.otelworkshop.Product\"\x00\x12R\n" +
+	"\x11CancelReservation\x12&.otelworkshop.CancelReservationRequest\x1a\x13.otelworkshop.Empty\"\x00\x12^\n" +
+	"\x12GetPurchaseHistory\x12'.otelworkshop.GetPurchaseHistoryRequest\x1a\x1d.otelworkshop.PurchaseHistory\"\x00\x12X\n" +
+	"\x0eWatchInventory\x12#.otelworkshop.WatchInventoryRequest\x1a\x1d.otelworkshop.InventoryChange\"\x000\x01B\x17Z\x15genproto/otelworkshopb\x06proto3"
+
 var (
 	file_workshop_proto_rawDescOnce sync.Once
-	file_workshop_proto_rawDescData = file_workshop_proto_rawDesc
+	file_workshop_proto_rawDescData []byte
 )
 
 func file_workshop_proto_rawDescGZIP() []byte {
 	file_workshop_proto_rawDescOnce.Do(func() {
-		file_workshop_proto_rawDescData = protoimpl.X.CompressGZIP(file_workshop_proto_rawDescData)
+		file_workshop_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_workshop_proto_rawDesc), len(file_workshop_proto_rawDesc)))
 	})
 	return file_workshop_proto_rawDescData
 }
 
-var file_workshop_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_workshop_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_workshop_proto_goTypes = []any{
-	(*Empty)(nil),                // 0: otelworkshop.Empty
-	(*Product)(nil),              // 1: otelworkshop.Product
-	(*ListProductsResponse)(nil), // 2: otelworkshop.ListProductsResponse
-	(*BuyProductRequest)(nil),    // 3: otelworkshop.BuyProductRequest
+	(*Empty)(nil),                     // 0: otelworkshop.Empty
+	(*Product)(nil),                   // 1: otelworkshop.Product
+	(*ProductFilter)(nil),             // 2: otelworkshop.ProductFilter
+	(*ListProductsRequest)(nil),       // 3: otelworkshop.ListProductsRequest
+	(*ListProductsResponse)(nil),      // 4: otelworkshop.ListProductsResponse
+	(*WatchInventoryRequest)(nil),     // 5: otelworkshop.WatchInventoryRequest
+	(*InventoryChange)(nil),           // 6: otelworkshop.InventoryChange
+	(*BuyProductRequest)(nil),         // 7: otelworkshop.BuyProductRequest
+	(*CheckoutRequest)(nil),           // 8: otelworkshop.CheckoutRequest
+	(*CheckoutResponse)(nil),          // 9: otelworkshop.CheckoutResponse
+	(*ReserveProductRequest)(nil),     // 10: otelworkshop.ReserveProductRequest
+	(*Reservation)(nil),               // 11: otelworkshop.Reservation
+	(*ConfirmReservationRequest)(nil), // 12: otelworkshop.ConfirmReservationRequest
+	(*CancelReservationRequest)(nil),  // 13: otelworkshop.CancelReservationRequest
+	(*GetPurchaseHistoryRequest)(nil), // 14: otelworkshop.GetPurchaseHistoryRequest
+	(*Purchase)(nil),                  // 15: otelworkshop.Purchase
+	(*PurchaseHistory)(nil),           // 16: otelworkshop.PurchaseHistory
+	(*timestamppb.Timestamp)(nil),     // 17: google.protobuf.Timestamp
 }
 var file_workshop_proto_depIdxs = []int32{
-	1, // 0: otelworkshop.ListProductsResponse.products:type_name -> otelworkshop.Product
-	1, // 1: otelworkshop.BuyProductRequest.product:type_name -> otelworkshop.Product
-	0, // 2: otelworkshop.ShopService.ListProducts:input_type -> otelworkshop.Empty
-	3, // 3: otelworkshop.ShopService.BuyProduct:input_type -> otelworkshop.BuyProductRequest
-	2, // 4: otelworkshop.ShopService.ListProducts:output_type -> otelworkshop.ListProductsResponse
-	1, // 5: otelworkshop.ShopService.BuyProduct:output_type -> otelworkshop.Product
-	4, // [4:6] is the sub-list for method output_type
-	2, // [2:4] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	2,  // 0: otelworkshop.ListProductsRequest.filter:type_name -> otelworkshop.ProductFilter
+	1,  // 1: otelworkshop.ListProductsResponse.products:type_name -> otelworkshop.Product
+	2,  // 2: otelworkshop.WatchInventoryRequest.filter:type_name -> otelworkshop.ProductFilter
+	1,  // 3: otelworkshop.InventoryChange.product:type_name -> otelworkshop.Product
+	1,  // 4: otelworkshop.BuyProductRequest.product:type_name -> otelworkshop.Product
+	1,  // 5: otelworkshop.CheckoutRequest.lines:type_name -> otelworkshop.Product
+	1,  // 6: otelworkshop.CheckoutResponse.lines:type_name -> otelworkshop.Product
+	1,  // 7: otelworkshop.ReserveProductRequest.product:type_name -> otelworkshop.Product
+	1,  // 8: otelworkshop.Reservation.product:type_name -> otelworkshop.Product
+	17, // 9: otelworkshop.Reservation.expires_at:type_name -> google.protobuf.Timestamp
+	1,  // 10: otelworkshop.Purchase.product:type_name -> otelworkshop.Product
+	17, // 11: otelworkshop.Purchase.purchased_at:type_name -> google.protobuf.Timestamp
+	15, // 12: otelworkshop.PurchaseHistory.purchases:type_name -> otelworkshop.Purchase
+	3,  // 13: otelworkshop.ShopService.ListProducts:input_type -> otelworkshop.ListProductsRequest
+	7,  // 14: otelworkshop.ShopService.BuyProduct:input_type -> otelworkshop.BuyProductRequest
+	8,  // 15: otelworkshop.ShopService.Checkout:input_type -> otelworkshop.CheckoutRequest
+	10, // 16: otelworkshop.ShopService.ReserveProduct:input_type -> otelworkshop.ReserveProductRequest
+	12, // 17: otelworkshop.ShopService.ConfirmReservation:input_type -> otelworkshop.ConfirmReservationRequest
+	13, // 18: otelworkshop.ShopService.CancelReservation:input_type -> otelworkshop.CancelReservationRequest
+	14, // 19: otelworkshop.ShopService.GetPurchaseHistory:input_type -> otelworkshop.GetPurchaseHistoryRequest
+	5,  // 20: otelworkshop.ShopService.WatchInventory:input_type -> otelworkshop.WatchInventoryRequest
+	4,  // 21: otelworkshop.ShopService.ListProducts:output_type -> otelworkshop.ListProductsResponse
+	1,  // 22: otelworkshop.ShopService.BuyProduct:output_type -> otelworkshop.Product
+	9,  // 23: otelworkshop.ShopService.Checkout:output_type -> otelworkshop.CheckoutResponse
+	11, // 24: otelworkshop.ShopService.ReserveProduct:output_type -> otelworkshop.Reservation
+	1,  // 25: otelworkshop.ShopService.ConfirmReservation:output_type -> otelworkshop.Product
+	0,  // 26: otelworkshop.ShopService.CancelReservation:output_type -> otelworkshop.Empty
+	16, // 27: otelworkshop.ShopService.GetPurchaseHistory:output_type -> otelworkshop.PurchaseHistory
+	6,  // 28: otelworkshop.ShopService.WatchInventory:output_type -> otelworkshop.InventoryChange
+	21, // [21:29] is the sub-list for method output_type
+	13, // [13:21] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_workshop_proto_init() }
@@ -306,63 +1113,14 @@ func file_workshop_proto_init() {
 	if File_workshop_proto != nil {
 		return
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_workshop_proto_msgTypes[0].Exporter = func(v any, i int) any {
-			switch v := v.(*Empty); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_workshop_proto_msgTypes[1].Exporter = func(v any, i int) any {
-			switch v := v.(*Product); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_workshop_proto_msgTypes[2].Exporter = func(v any, i int) any {
-			switch v := v.(*ListProductsResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_workshop_proto_msgTypes[3].Exporter = func(v any, i int) any {
-			switch v := v.(*BuyProductRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-	}
+	file_workshop_proto_msgTypes[2].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
-			RawDescriptor: file_workshop_proto_rawDesc,
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_workshop_proto_rawDesc), len(file_workshop_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -371,7 +1129,6 @@ func file_workshop_proto_init() {
 		MessageInfos:      file_workshop_proto_msgTypes,
 	}.Build()
 	File_workshop_proto = out.File
-	file_workshop_proto_rawDesc = nil
 	file_workshop_proto_goTypes = nil
 	file_workshop_proto_depIdxs = nil
 }
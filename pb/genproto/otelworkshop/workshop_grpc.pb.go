@@ -1,7 +1,7 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
 // versions:
 // - protoc-gen-go-grpc v1.5.1
-// - protoc             v4.25.2
+// - protoc             (unknown)
 // source: workshop.proto
 
 package otelworkshop
@@ -19,16 +19,34 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ShopService_ListProducts_FullMethodName = "/otelworkshop.ShopService/ListProducts"
-	ShopService_BuyProduct_FullMethodName   = "/otelworkshop.ShopService/BuyProduct"
+	ShopService_ListProducts_FullMethodName       = "/otelworkshop.ShopService/ListProducts"
+	ShopService_BuyProduct_FullMethodName         = "/otelworkshop.ShopService/BuyProduct"
+	ShopService_Checkout_FullMethodName           = "/otelworkshop.ShopService/Checkout"
+	ShopService_ReserveProduct_FullMethodName     = "/otelworkshop.ShopService/ReserveProduct"
+	ShopService_ConfirmReservation_FullMethodName = "/otelworkshop.ShopService/ConfirmReservation"
+	ShopService_CancelReservation_FullMethodName  = "/otelworkshop.ShopService/CancelReservation"
+	ShopService_GetPurchaseHistory_FullMethodName = "/otelworkshop.ShopService/GetPurchaseHistory"
+	ShopService_WatchInventory_FullMethodName     = "/otelworkshop.ShopService/WatchInventory"
 )
 
 // ShopServiceClient is the client API for ShopService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ShopServiceClient interface {
-	ListProducts(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ListProductsResponse, error)
+	ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error)
 	BuyProduct(ctx context.Context, in *BuyProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// Checkout buys every line or none of them.
+	Checkout(ctx context.Context, in *CheckoutRequest, opts ...grpc.CallOption) (*CheckoutResponse, error)
+	// ReserveProduct takes the product out of stock until the reservation is
+	// confirmed, cancelled or expires, whichever comes first. Cancelled and
+	// expired reservations return the product to stock.
+	ReserveProduct(ctx context.Context, in *ReserveProductRequest, opts ...grpc.CallOption) (*Reservation, error)
+	ConfirmReservation(ctx context.Context, in *ConfirmReservationRequest, opts ...grpc.CallOption) (*Product, error)
+	CancelReservation(ctx context.Context, in *CancelReservationRequest, opts ...grpc.CallOption) (*Empty, error)
+	// GetPurchaseHistory lists the latest purchases of a customer. Purchases
+	// are attributed to the customer.id baggage member of the buying call.
+	GetPurchaseHistory(ctx context.Context, in *GetPurchaseHistoryRequest, opts ...grpc.CallOption) (*PurchaseHistory, error)
+	WatchInventory(ctx context.Context, in *WatchInventoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[InventoryChange], error)
 }
 
 type shopServiceClient struct {
@@ -39,7 +57,7 @@ func NewShopServiceClient(cc grpc.ClientConnInterface) ShopServiceClient {
 	return &shopServiceClient{cc}
 }
 
-func (c *shopServiceClient) ListProducts(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ListProductsResponse, error) {
+func (c *shopServiceClient) ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ListProductsResponse)
 	err := c.cc.Invoke(ctx, ShopService_ListProducts_FullMethodName, in, out, cOpts...)
@@ -59,12 +77,93 @@ func (c *shopServiceClient) BuyProduct(ctx context.Context, in *BuyProductReques
 	return out, nil
 }
 
+func (c *shopServiceClient) Checkout(ctx context.Context, in *CheckoutRequest, opts ...grpc.CallOption) (*CheckoutResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckoutResponse)
+	err := c.cc.Invoke(ctx, ShopService_Checkout_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shopServiceClient) ReserveProduct(ctx context.Context, in *ReserveProductRequest, opts ...grpc.CallOption) (*Reservation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Reservation)
+	err := c.cc.Invoke(ctx, ShopService_ReserveProduct_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shopServiceClient) ConfirmReservation(ctx context.Context, in *ConfirmReservationRequest, opts ...grpc.CallOption) (*Product, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Product)
+	err := c.cc.Invoke(ctx, ShopService_ConfirmReservation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shopServiceClient) CancelReservation(ctx context.Context, in *CancelReservationRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, ShopService_CancelReservation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shopServiceClient) GetPurchaseHistory(ctx context.Context, in *GetPurchaseHistoryRequest, opts ...grpc.CallOption) (*PurchaseHistory, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PurchaseHistory)
+	err := c.cc.Invoke(ctx, ShopService_GetPurchaseHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shopServiceClient) WatchInventory(ctx context.Context, in *WatchInventoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[InventoryChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ShopService_ServiceDesc.Streams[0], ShopService_WatchInventory_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchInventoryRequest, InventoryChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ShopService_WatchInventoryClient = grpc.ServerStreamingClient[InventoryChange]
+
 // ShopServiceServer is the server API for ShopService service.
 // All implementations must embed UnimplementedShopServiceServer
 // for forward compatibility.
 type ShopServiceServer interface {
-	ListProducts(context.Context, *Empty) (*ListProductsResponse, error)
+	ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error)
 	BuyProduct(context.Context, *BuyProductRequest) (*Product, error)
+	// Checkout buys every line or none of them.
+	Checkout(context.Context, *CheckoutRequest) (*CheckoutResponse, error)
+	// ReserveProduct takes the product out of stock until the reservation is
+	// confirmed, cancelled or expires, whichever comes first. Cancelled and
+	// expired reservations return the product to stock.
+	ReserveProduct(context.Context, *ReserveProductRequest) (*Reservation, error)
+	ConfirmReservation(context.Context, *ConfirmReservationRequest) (*Product, error)
+	CancelReservation(context.Context, *CancelReservationRequest) (*Empty, error)
+	// GetPurchaseHistory lists the latest purchases of a customer. Purchases
+	// are attributed to the customer.id baggage member of the buying call.
+	GetPurchaseHistory(context.Context, *GetPurchaseHistoryRequest) (*PurchaseHistory, error)
+	WatchInventory(*WatchInventoryRequest, grpc.ServerStreamingServer[InventoryChange]) error
 	mustEmbedUnimplementedShopServiceServer()
 }
 
@@ -75,12 +174,30 @@ type ShopServiceServer interface {
 // pointer dereference when methods are called.
 type UnimplementedShopServiceServer struct{}
 
-func (UnimplementedShopServiceServer) ListProducts(context.Context, *Empty) (*ListProductsResponse, error) {
+func (UnimplementedShopServiceServer) ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListProducts not implemented")
 }
 func (UnimplementedShopServiceServer) BuyProduct(context.Context, *BuyProductRequest) (*Product, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BuyProduct not implemented")
 }
+func (UnimplementedShopServiceServer) Checkout(context.Context, *CheckoutRequest) (*CheckoutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Checkout not implemented")
+}
+func (UnimplementedShopServiceServer) ReserveProduct(context.Context, *ReserveProductRequest) (*Reservation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReserveProduct not implemented")
+}
+func (UnimplementedShopServiceServer) ConfirmReservation(context.Context, *ConfirmReservationRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmReservation not implemented")
+}
+func (UnimplementedShopServiceServer) CancelReservation(context.Context, *CancelReservationRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelReservation not implemented")
+}
+func (UnimplementedShopServiceServer) GetPurchaseHistory(context.Context, *GetPurchaseHistoryRequest) (*PurchaseHistory, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPurchaseHistory not implemented")
+}
+func (UnimplementedShopServiceServer) WatchInventory(*WatchInventoryRequest, grpc.ServerStreamingServer[InventoryChange]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchInventory not implemented")
+}
 func (UnimplementedShopServiceServer) mustEmbedUnimplementedShopServiceServer() {}
 func (UnimplementedShopServiceServer) testEmbeddedByValue()                     {}
 
@@ -103,7 +220,7 @@ func RegisterShopServiceServer(s grpc.ServiceRegistrar, srv ShopServiceServer) {
 }
 
 func _ShopService_ListProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Empty)
+	in := new(ListProductsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -115,7 +232,7 @@ func _ShopService_ListProducts_Handler(srv interface{}, ctx context.Context, dec
 		FullMethod: ShopService_ListProducts_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ShopServiceServer).ListProducts(ctx, req.(*Empty))
+		return srv.(ShopServiceServer).ListProducts(ctx, req.(*ListProductsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -138,6 +255,107 @@ func _ShopService_BuyProduct_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ShopService_Checkout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckoutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShopServiceServer).Checkout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShopService_Checkout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShopServiceServer).Checkout(ctx, req.(*CheckoutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShopService_ReserveProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShopServiceServer).ReserveProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShopService_ReserveProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShopServiceServer).ReserveProduct(ctx, req.(*ReserveProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShopService_ConfirmReservation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmReservationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShopServiceServer).ConfirmReservation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShopService_ConfirmReservation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShopServiceServer).ConfirmReservation(ctx, req.(*ConfirmReservationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShopService_CancelReservation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelReservationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShopServiceServer).CancelReservation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShopService_CancelReservation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShopServiceServer).CancelReservation(ctx, req.(*CancelReservationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShopService_GetPurchaseHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPurchaseHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShopServiceServer).GetPurchaseHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShopService_GetPurchaseHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShopServiceServer).GetPurchaseHistory(ctx, req.(*GetPurchaseHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShopService_WatchInventory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchInventoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ShopServiceServer).WatchInventory(m, &grpc.GenericServerStream[WatchInventoryRequest, InventoryChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ShopService_WatchInventoryServer = grpc.ServerStreamingServer[InventoryChange]
+
 // ShopService_ServiceDesc is the grpc.ServiceDesc for ShopService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,7 +371,33 @@ var ShopService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "BuyProduct",
 			Handler:    _ShopService_BuyProduct_Handler,
 		},
+		{
+			MethodName: "Checkout",
+			Handler:    _ShopService_Checkout_Handler,
+		},
+		{
+			MethodName: "ReserveProduct",
+			Handler:    _ShopService_ReserveProduct_Handler,
+		},
+		{
+			MethodName: "ConfirmReservation",
+			Handler:    _ShopService_ConfirmReservation_Handler,
+		},
+		{
+			MethodName: "CancelReservation",
+			Handler:    _ShopService_CancelReservation_Handler,
+		},
+		{
+			MethodName: "GetPurchaseHistory",
+			Handler:    _ShopService_GetPurchaseHistory_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchInventory",
+			Handler:       _ShopService_WatchInventory_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "workshop.proto",
 }